@@ -0,0 +1,189 @@
+package ql
+
+import (
+	"encoding/binary"
+	"fmt"
+	"math"
+	"reflect"
+	"time"
+
+	"github.com/akamajoris/ngorm/model"
+)
+
+// arrayKinds are the element kinds of the arrays that can be serialized into a
+// blob column, with the size of their encoded form. int and uint elements are
+// always encoded with 64 bits.
+var arrayKinds = map[reflect.Kind]int{
+	reflect.Bool:       1,
+	reflect.Int:        8,
+	reflect.Int8:       1,
+	reflect.Int16:      2,
+	reflect.Int32:      4,
+	reflect.Int64:      8,
+	reflect.Uint:       8,
+	reflect.Uint8:      1,
+	reflect.Uint16:     2,
+	reflect.Uint32:     4,
+	reflect.Uint64:     8,
+	reflect.Float32:    4,
+	reflect.Float64:    8,
+	reflect.Complex64:  8,
+	reflect.Complex128: 16,
+}
+
+// isPrimitiveArray returns true if t is a fixed size array of booleans or
+// numbers.
+func isPrimitiveArray(t reflect.Type) bool {
+	if t.Kind() != reflect.Array {
+		return false
+	}
+	_, ok := arrayKinds[t.Elem().Kind()]
+	return ok
+}
+
+// arrayType returns the column type of an array field. ql has no array type,
+// arrays of booleans and numbers are stored as blobs when the field is tagged
+// serialize:blob, the values then go through EncodeArray and DecodeArray.
+func arrayType(field *model.StructField, t reflect.Type) (string, error) {
+	if !isPrimitiveArray(t) {
+		return "", fmt.Errorf("invalid sql type %s for ql, only arrays of booleans and numbers can be stored", t)
+	}
+	if field.TagSettings["SERIALIZE"] != "blob" {
+		return "", fmt.Errorf("invalid sql type %s for ql, tag the field with serialize:blob to store it as a blob", t)
+	}
+	return "blob", nil
+}
+
+// timeSliceType is the type of the fields stored in a side table generated by
+// TimeSliceTableSQL.
+var timeSliceType = reflect.TypeOf([]time.Time(nil))
+
+// TimeSliceTableSQL returns the statements creating the side table that holds
+// the values of field, a []time.Time field of the records of ownerTable. ql
+// has no array type, so each value is stored as a record with the id() of its
+// owner, and an index on the owner makes looking them up cheap:
+//
+//	CREATE TABLE events_times (owner_id int64, value time);
+//	CREATE INDEX events_times_owner_id_idx ON events_times (owner_id)
+//
+// The statements have to run in a transaction. The side table is named after
+// the owner table and the field, the field itself has no column.
+func (q *QL) TimeSliceTableSQL(ownerTable, field string) (string, error) {
+	name := ownerTable + "_" + field
+	if err := q.ValidateTableName(name); err != nil {
+		return "", err
+	}
+	i := q.newIndex(name, q.IndexName(name, []string{"owner_id"}), []string{"owner_id"}, false)
+	return fmt.Sprintf("CREATE TABLE %s (%s int64, %s time);\n%s",
+		q.Quote(name), q.Quote("owner_id"), q.Quote("value"), createIndexSQL(i)), nil
+}
+
+// EncodeArray encodes the array v for a column of a field tagged
+// serialize:blob. The encoding holds the number of elements followed by the
+// elements in order.
+func EncodeArray(v interface{}) ([]byte, error) {
+	a := reflect.ValueOf(v)
+	if !isPrimitiveArray(a.Type()) {
+		return nil, fmt.Errorf("ql: cannot encode %T as an array", v)
+	}
+	size := arrayKinds[a.Type().Elem().Kind()]
+	b := make([]byte, binary.MaxVarintLen64, binary.MaxVarintLen64+a.Len()*size)
+	b = b[:binary.PutUvarint(b, uint64(a.Len()))]
+	for i := 0; i < a.Len(); i++ {
+		b = appendElem(b, a.Index(i), size)
+	}
+	return b, nil
+}
+
+func appendElem(b []byte, e reflect.Value, size int) []byte {
+	var buf [16]byte
+	switch e.Kind() {
+	case reflect.Bool:
+		if e.Bool() {
+			buf[0] = 1
+		}
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64:
+		putUint(buf[:size], uint64(e.Int()))
+	case reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64:
+		putUint(buf[:size], e.Uint())
+	case reflect.Float32:
+		binary.LittleEndian.PutUint32(buf[:], math.Float32bits(float32(e.Float())))
+	case reflect.Float64:
+		binary.LittleEndian.PutUint64(buf[:], math.Float64bits(e.Float()))
+	case reflect.Complex64:
+		c := e.Complex()
+		binary.LittleEndian.PutUint32(buf[:], math.Float32bits(float32(real(c))))
+		binary.LittleEndian.PutUint32(buf[4:], math.Float32bits(float32(imag(c))))
+	case reflect.Complex128:
+		c := e.Complex()
+		binary.LittleEndian.PutUint64(buf[:], math.Float64bits(real(c)))
+		binary.LittleEndian.PutUint64(buf[8:], math.Float64bits(imag(c)))
+	}
+	return append(b, buf[:size]...)
+}
+
+func putUint(b []byte, v uint64) {
+	for i := range b {
+		b[i] = byte(v >> (8 * uint(i)))
+	}
+}
+
+func getUint(b []byte) uint64 {
+	var v uint64
+	for i := range b {
+		v |= uint64(b[i]) << (8 * uint(i))
+	}
+	return v
+}
+
+// DecodeArray decodes b, as produced by EncodeArray, into the array pointed to
+// by dst. The number and type of the elements must match.
+func DecodeArray(b []byte, dst interface{}) error {
+	p := reflect.ValueOf(dst)
+	if p.Kind() != reflect.Ptr || p.IsNil() || !isPrimitiveArray(p.Elem().Type()) {
+		return fmt.Errorf("ql: cannot decode an array into %T", dst)
+	}
+	a := p.Elem()
+	n, read := binary.Uvarint(b)
+	if read <= 0 {
+		return fmt.Errorf("ql: invalid array encoding")
+	}
+	if n != uint64(a.Len()) {
+		return fmt.Errorf("ql: cannot decode %d elements into %s", n, a.Type())
+	}
+	size := arrayKinds[a.Type().Elem().Kind()]
+	b = b[read:]
+	if len(b) != a.Len()*size {
+		return fmt.Errorf("ql: invalid array encoding for %s", a.Type())
+	}
+	for i := 0; i < a.Len(); i++ {
+		setElem(a.Index(i), b[i*size:(i+1)*size])
+	}
+	return nil
+}
+
+func setElem(e reflect.Value, b []byte) {
+	switch e.Kind() {
+	case reflect.Bool:
+		e.SetBool(b[0] != 0)
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64:
+		v := getUint(b)
+		// sign extend the narrow integers
+		shift := 64 - 8*uint(len(b))
+		e.SetInt(int64(v<<shift) >> shift)
+	case reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64:
+		e.SetUint(getUint(b))
+	case reflect.Float32:
+		e.SetFloat(float64(math.Float32frombits(binary.LittleEndian.Uint32(b))))
+	case reflect.Float64:
+		e.SetFloat(math.Float64frombits(binary.LittleEndian.Uint64(b)))
+	case reflect.Complex64:
+		re := math.Float32frombits(binary.LittleEndian.Uint32(b))
+		im := math.Float32frombits(binary.LittleEndian.Uint32(b[4:]))
+		e.SetComplex(complex(float64(re), float64(im)))
+	case reflect.Complex128:
+		re := math.Float64frombits(binary.LittleEndian.Uint64(b))
+		im := math.Float64frombits(binary.LittleEndian.Uint64(b[8:]))
+		e.SetComplex(complex(re, im))
+	}
+}
@@ -0,0 +1,138 @@
+package ql
+
+import (
+	"reflect"
+	"strings"
+	"testing"
+	"time"
+)
+
+type Shape struct {
+	ID     int64
+	Color  [3]float64 `sql:"serialize:blob"`
+	Points [4]int16   `sql:"serialize:blob"`
+	Normal [3]float32
+}
+
+func TestQL_DataTypeOfArray(t *testing.T) {
+	q := Memory()
+	for _, f := range modelFields(t, &Shape{}) {
+		s, err := q.DataTypeOf(f)
+		switch f.Name {
+		case "Color", "Points":
+			if err != nil {
+				t.Fatal(err)
+			}
+			if s != "blob" {
+				t.Errorf("%s: expected blob got %s", f.Name, s)
+			}
+		case "Normal":
+			if err == nil || !strings.Contains(err.Error(), "serialize:blob") {
+				t.Errorf("expected an error mentioning serialize:blob got %v", err)
+			}
+		}
+	}
+}
+
+func TestEncodeArray(t *testing.T) {
+	q := openMemory(t)
+	execTx(t, q.db, "CREATE TABLE shapes (color blob, points blob)")
+	color := [3]float64{0.25, -1, 1e10}
+	points := [4]int16{-32768, -1, 0, 32767}
+	c, err := EncodeArray(color)
+	if err != nil {
+		t.Fatal(err)
+	}
+	p, err := EncodeArray(points)
+	if err != nil {
+		t.Fatal(err)
+	}
+	execTx(t, q.db, "INSERT INTO shapes VALUES ($1, $2)", c, p)
+
+	var cb, pb []byte
+	err = q.db.QueryRow("SELECT color, points FROM shapes").Scan(&cb, &pb)
+	if err != nil {
+		t.Fatal(err)
+	}
+	var color2 [3]float64
+	if err = DecodeArray(cb, &color2); err != nil {
+		t.Fatal(err)
+	}
+	if color2 != color {
+		t.Errorf("expected %v got %v", color, color2)
+	}
+	var points2 [4]int16
+	if err = DecodeArray(pb, &points2); err != nil {
+		t.Fatal(err)
+	}
+	if points2 != points {
+		t.Errorf("expected %v got %v", points, points2)
+	}
+
+	var short [2]float64
+	if err = DecodeArray(cb, &short); err == nil {
+		t.Error("expected an error for a different element count")
+	}
+	if _, err = EncodeArray([]float64{1}); err == nil {
+		t.Error("expected an error for a slice")
+	}
+}
+
+type Meeting struct {
+	ID    int64
+	Title string
+	Times []time.Time
+}
+
+func TestQL_TimeSliceTableSQL(t *testing.T) {
+	q := openMemory(t)
+	fields := modelFields(t, &Meeting{})
+	if _, err := q.DataTypeOf(fields[2]); err == nil || !strings.Contains(err.Error(), "TimeSliceTableSQL") {
+		t.Errorf("expected an error mentioning TimeSliceTableSQL got %v", err)
+	}
+	create, err := q.CreateTableSQL("meetings", fields)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if create != "CREATE TABLE meetings (id int64, title string)" {
+		t.Errorf("expected the times to be skipped got %s", create)
+	}
+	s, err := q.TimeSliceTableSQL("meetings", "times")
+	if err != nil {
+		t.Fatal(err)
+	}
+	exp := "CREATE TABLE meetings_times (owner_id int64, value time);\n" +
+		"CREATE INDEX meetings_times_owner_id_idx ON meetings_times (owner_id)"
+	if s != exp {
+		t.Errorf("expected %s got %s", exp, s)
+	}
+	execTx(t, q.db, create+";"+s)
+	if !q.HasIndexOnColumns("meetings_times", []string{"owner_id"}) {
+		t.Error("expected the owner index")
+	}
+	day := func(d int) time.Time {
+		return time.Date(2017, 3, d, 0, 0, 0, 0, time.UTC)
+	}
+	execTx(t, q.db, "INSERT INTO meetings_times VALUES (1, $1), (2, $2), (1, $3)", day(1), day(2), day(3))
+	rows, err := q.db.Query("SELECT value FROM meetings_times WHERE owner_id == 1 ORDER BY value")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer func() {
+		_ = rows.Close()
+	}()
+	var o []time.Time
+	for rows.Next() {
+		var v time.Time
+		if err = rows.Scan(&v); err != nil {
+			t.Fatal(err)
+		}
+		o = append(o, v.UTC())
+	}
+	if e := []time.Time{day(1), day(3)}; !reflect.DeepEqual(o, e) {
+		t.Errorf("expected %v got %v", e, o)
+	}
+	if _, err = q.TimeSliceTableSQL("other.meetings", "times"); err == nil {
+		t.Error("expected an error for an invalid name")
+	}
+}
@@ -0,0 +1,82 @@
+package ql
+
+import "errors"
+
+// ErrBatchWriterClosed is returned when writing to a BatchWriter after Close.
+var ErrBatchWriterClosed = errors.New("ql: batch writer is closed")
+
+// BatchWriter buffers rows for a table and inserts them with BatchInsert, one
+// transaction per batch, which is much faster than a transaction per row for
+// ingestion. Create it with NewBatchWriter. A BatchWriter is not safe for
+// concurrent use.
+type BatchWriter struct {
+	q         *QL
+	tableName string
+	columns   []string
+	flushSize int
+	rows      [][]interface{}
+	closed    bool
+}
+
+// NewBatchWriter returns a BatchWriter inserting into the columns of tableName
+// that flushes once flushSize rows are buffered. A flushSize below 1 flushes
+// every row.
+func (q *QL) NewBatchWriter(tableName string, columns []string, flushSize int) *BatchWriter {
+	if flushSize < 1 {
+		flushSize = 1
+	}
+	return &BatchWriter{
+		q:         q,
+		tableName: tableName,
+		columns:   columns,
+		flushSize: flushSize,
+	}
+}
+
+// Write buffers row, which holds one value per column in the order given to
+// NewBatchWriter, and flushes when the buffer is full. The error of that flush
+// is returned.
+func (w *BatchWriter) Write(row []interface{}) error {
+	if w.closed {
+		return ErrBatchWriterClosed
+	}
+	w.rows = append(w.rows, row)
+	if len(w.rows) < w.flushSize {
+		return nil
+	}
+	return w.Flush()
+}
+
+// Buffered returns the number of rows waiting to be flushed.
+func (w *BatchWriter) Buffered() int {
+	return len(w.rows)
+}
+
+// Flush inserts the buffered rows. When the insert fails nothing is inserted
+// and the rows stay buffered, so Flush can be called again.
+func (w *BatchWriter) Flush() error {
+	if len(w.rows) == 0 {
+		return nil
+	}
+	if w.q.db == nil {
+		return ErrNoDB
+	}
+	if err := w.q.BatchInsert(w.q.db, w.tableName, w.columns, w.rows); err != nil {
+		return err
+	}
+	w.rows = w.rows[:0]
+	return nil
+}
+
+// Close flushes the buffered rows and makes later writes fail with
+// ErrBatchWriterClosed. Closing a closed BatchWriter does nothing.
+func (w *BatchWriter) Close() error {
+	if w.closed {
+		return nil
+	}
+	if err := w.Flush(); err != nil {
+		return err
+	}
+	w.closed = true
+	return nil
+}
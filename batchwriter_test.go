@@ -0,0 +1,44 @@
+package ql
+
+import "testing"
+
+func TestQL_NewBatchWriter(t *testing.T) {
+	q := openMemory(t)
+	execTx(t, q.db, migration)
+	w := q.NewBatchWriter("Items", []string{"OrderID", "Qty"}, 3)
+	for i := 0; i < 7; i++ {
+		if err := w.Write([]interface{}{int64(i), int64(1)}); err != nil {
+			t.Fatal(err)
+		}
+	}
+	n, err := q.CountRows("Items")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if n != 6 || w.Buffered() != 1 {
+		t.Errorf("expected 6 flushed and 1 buffered rows got %d and %d", n, w.Buffered())
+	}
+	if err = w.Close(); err != nil {
+		t.Fatal(err)
+	}
+	if n, _ = q.CountRows("Items"); n != 7 {
+		t.Errorf("expected Close to flush the last row got %d rows", n)
+	}
+	if err = w.Write([]interface{}{int64(8), int64(1)}); err != ErrBatchWriterClosed {
+		t.Errorf("expected %v got %v", ErrBatchWriterClosed, err)
+	}
+
+	w = q.NewBatchWriter("Items", []string{"Missing"}, 2)
+	if err = w.Write([]interface{}{int64(1)}); err != nil {
+		t.Fatal(err)
+	}
+	if err = w.Write([]interface{}{int64(2)}); err == nil {
+		t.Error("expected the flush error")
+	}
+	if w.Buffered() != 2 {
+		t.Errorf("expected the failed rows to stay buffered got %d", w.Buffered())
+	}
+	if err = w.Close(); err == nil {
+		t.Error("expected the flush error")
+	}
+}
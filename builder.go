@@ -0,0 +1,58 @@
+package ql
+
+import "strconv"
+
+// SQLBuilder renders the SQL fragments that the dialect hands to ngorm. It
+// allows tweaking individual fragments without forking the dialect, embed
+// DefaultBuilder and override the methods that need to change.
+type SQLBuilder interface {
+	// Limit renders the LIMIT clause for a positive limit.
+	Limit(limit int64) string
+
+	// Offset renders the OFFSET clause for a positive offset.
+	Offset(offset int64) string
+
+	// BindVar renders the placeholder for the i'th argument.
+	BindVar(i int) string
+
+	// Quote quotes an identifier.
+	Quote(key string) string
+}
+
+// DefaultBuilder is the SQLBuilder used by the dialect unless another one is
+// set with SetSQLBuilder.
+type DefaultBuilder struct{}
+
+// Limit implements SQLBuilder.
+func (DefaultBuilder) Limit(limit int64) string {
+	return "LIMIT " + strconv.FormatInt(limit, 10)
+}
+
+// Offset implements SQLBuilder.
+func (DefaultBuilder) Offset(offset int64) string {
+	return "OFFSET " + strconv.FormatInt(offset, 10)
+}
+
+// BindVar implements SQLBuilder. ql uses $1 style placeholders.
+func (DefaultBuilder) BindVar(i int) string {
+	return "$" + strconv.FormatInt(int64(i), 10)
+}
+
+// Quote implements SQLBuilder. ql has no identifier quoting so key is returned
+// as is.
+func (DefaultBuilder) Quote(key string) string {
+	return key
+}
+
+// SetSQLBuilder sets the builder used to render SQL fragments. Passing nil
+// restores DefaultBuilder.
+func (q *QL) SetSQLBuilder(b SQLBuilder) {
+	q.builder = b
+}
+
+func (q QL) sqlBuilder() SQLBuilder {
+	if q.builder == nil {
+		return DefaultBuilder{}
+	}
+	return q.builder
+}
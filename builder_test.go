@@ -0,0 +1,79 @@
+package ql
+
+import (
+	"fmt"
+	"strconv"
+	"testing"
+)
+
+type topBuilder struct {
+	DefaultBuilder
+}
+
+func (topBuilder) Limit(limit int64) string {
+	return "LIMIT (" + strconv.FormatInt(limit, 10) + ")"
+}
+
+func TestQL_SetSQLBuilder(t *testing.T) {
+	q := Memory()
+	exp := " LIMIT 5 OFFSET 10"
+	o := q.LimitAndOffsetSQL(5, 10)
+	if o != exp {
+		t.Errorf("expected %s got %s", exp, o)
+	}
+
+	q.SetSQLBuilder(topBuilder{})
+	exp = " LIMIT (5) OFFSET 10"
+	o = q.LimitAndOffsetSQL(5, 10)
+	if o != exp {
+		t.Errorf("expected %s got %s", exp, o)
+	}
+	if q.BindVar(2) != "$2" {
+		t.Errorf("expected $2 got %s", q.BindVar(2))
+	}
+	if q.Quote("name") != "name" {
+		t.Errorf("expected name got %s", q.Quote("name"))
+	}
+
+	// other dialects are not affected
+	o = Memory().LimitAndOffsetSQL(5, 10)
+	if o != " LIMIT 5 OFFSET 10" {
+		t.Errorf("expected %s got %s", " LIMIT 5 OFFSET 10", o)
+	}
+
+	q.SetSQLBuilder(nil)
+	o = q.LimitAndOffsetSQL(5, 0)
+	if o != " LIMIT 5" {
+		t.Errorf("expected %s got %s", " LIMIT 5", o)
+	}
+}
+
+func TestQL_BindVarFrom(t *testing.T) {
+	q := openMemory(t)
+	if o := q.BindVar(1); o != "$1" {
+		t.Errorf("expected $1 got %s", o)
+	}
+	if o := q.BindVarFrom(10, 2); o != "$12" {
+		t.Errorf("expected $12 got %s", o)
+	}
+
+	// fragment builds a condition numbering its own arguments from 1.
+	fragment := func(base int, column string) string {
+		return fmt.Sprintf("%s >= %s && %s <= %s", column, q.BindVarFrom(base, 1), column, q.BindVarFrom(base, 2))
+	}
+	where := fragment(0, "OrderID") + " && " + fragment(2, "Qty")
+	exp := "OrderID >= $1 && OrderID <= $2 && Qty >= $3 && Qty <= $4"
+	if where != exp {
+		t.Fatalf("expected %s got %s", exp, where)
+	}
+	execTx(t, q.db, migration)
+	execTx(t, q.db, "INSERT INTO Items VALUES (1, 1, 5), (2, 2, 1), (3, 3, 5), (9, 9, 5)")
+	var count int
+	err := q.db.QueryRow("SELECT count() FROM Items WHERE "+where, 1, 3, 2, 8).Scan(&count)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if count != 2 {
+		t.Errorf("expected 2 got %d", count)
+	}
+}
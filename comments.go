@@ -0,0 +1,107 @@
+package ql
+
+import (
+	"fmt"
+
+	"github.com/akamajoris/ngorm/model"
+)
+
+// commentsTable is the side table that stores column comments, which ql has
+// no support for.
+const commentsTable = "__ql_comments"
+
+// SetColumnComment stores comment for columnName of tableName. The comments
+// are kept in a side table that is created on first use, they are not tied to
+// the schema and survive dropping and recreating the table. An empty comment
+// removes the stored one.
+func (q *QL) SetColumnComment(tableName, columnName, comment string) error {
+	return q.transaction(func(tx queryer) error {
+		return setColumnComment(tx, tableName, columnName, comment)
+	})
+}
+
+func setColumnComment(tx queryer, tableName, columnName, comment string) error {
+	_, err := tx.Exec(fmt.Sprintf("CREATE TABLE IF NOT EXISTS %s (TableName string, ColumnName string, Comment string)", commentsTable))
+	if err != nil {
+		return err
+	}
+	_, err = tx.Exec(fmt.Sprintf("DELETE FROM %s WHERE TableName == $1 && ColumnName == $2", commentsTable), tableName, columnName)
+	if err != nil || comment == "" {
+		return err
+	}
+	_, err = tx.Exec(fmt.Sprintf("INSERT INTO %s VALUES ($1, $2, $3)", commentsTable), tableName, columnName, comment)
+	return err
+}
+
+// ColumnComment returns the comment stored for columnName of tableName, or an
+// empty string if there is none.
+func (q *QL) ColumnComment(tableName, columnName string) (string, error) {
+	if q.db == nil {
+		return "", ErrNoDB
+	}
+	if !q.HasTable(commentsTable) {
+		return "", nil
+	}
+	query := fmt.Sprintf("SELECT Comment FROM %s WHERE TableName == $1 && ColumnName == $2", commentsTable)
+	comment, err := q.ScalarString(query, tableName, columnName)
+	switch err {
+	case nil:
+		return comment, nil
+	case ErrNoValue:
+		return "", nil
+	default:
+		return "", err
+	}
+}
+
+// CreateTable creates tableName with the columns for fields, see
+// CreateTableSQL, and records the comments given with the comment directive,
+// like `gorm:"comment:when the order was placed"`, in the same transaction.
+func (q *QL) CreateTable(tableName string, fields []*model.StructField) error {
+	return q.createTable(tableName, fields, false)
+}
+
+// CreateTableIfNotExists is like CreateTable but does nothing when tableName
+// already exists, so migrations can be run again. The check and the creation
+// happen in the same transaction. The existing table is not compared with
+// fields.
+func (q *QL) CreateTableIfNotExists(tableName string, fields []*model.StructField) error {
+	return q.createTable(tableName, fields, true)
+}
+
+func (q *QL) createTable(tableName string, fields []*model.StructField, ifNotExists bool) error {
+	query, err := q.CreateTableSQL(tableName, fields)
+	if err != nil {
+		return err
+	}
+	return q.transaction(func(tx queryer) error {
+		_, err := q.createTableTx(tx, query, tableName, fields, ifNotExists)
+		return err
+	})
+}
+
+// createTableTx runs the CREATE TABLE statement query in tx and stores the
+// comments of fields. It returns false if ifNotExists is set and tableName
+// already exists.
+func (q *QL) createTableTx(tx queryer, query, tableName string, fields []*model.StructField, ifNotExists bool) (bool, error) {
+	if ifNotExists {
+		n, err := scalarInt64(tx, "select count() from __Table where Name=$1", tableName)
+		if err != nil || n > 0 {
+			return false, err
+		}
+	}
+	if _, err := tx.Exec(query); err != nil {
+		return false, err
+	}
+	for _, field := range fields {
+		comment := field.TagSettings["COMMENT"]
+		if !field.IsNormal || field.IsIgnored || comment == "" {
+			continue
+		}
+		err := setColumnComment(tx, tableName, q.ColumnName(field), comment)
+		if err != nil {
+			return false, err
+		}
+	}
+	return true, nil
+}
@@ -0,0 +1,100 @@
+package ql
+
+import "testing"
+
+type Invoice struct {
+	Number string `gorm:"comment:printed on the invoice"`
+	Total  int64
+}
+
+func TestQL_SetColumnComment(t *testing.T) {
+	q := openMemory(t)
+	execTx(t, q.db, migration)
+	c, err := q.ColumnComment("Orders", "Date")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if c != "" {
+		t.Errorf("expected no comment got %s", c)
+	}
+
+	exp := "the day the order was placed"
+	if err = q.SetColumnComment("Orders", "Date", exp); err != nil {
+		t.Fatal(err)
+	}
+	if err = q.SetColumnComment("Orders", "Date", exp); err != nil {
+		t.Fatal(err)
+	}
+	c, err = q.ColumnComment("Orders", "Date")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if c != exp {
+		t.Errorf("expected %s got %s", exp, c)
+	}
+
+	// comments are independent of the schema
+	execTx(t, q.db, "DROP TABLE Orders")
+	c, err = q.ColumnComment("Orders", "Date")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if c != exp {
+		t.Errorf("expected %s got %s", exp, c)
+	}
+
+	if err = q.SetColumnComment("Orders", "Date", ""); err != nil {
+		t.Fatal(err)
+	}
+	c, err = q.ColumnComment("Orders", "Date")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if c != "" {
+		t.Errorf("expected no comment got %s", c)
+	}
+}
+
+func TestQL_CreateTable(t *testing.T) {
+	q := openMemory(t)
+	if err := q.CreateTable("invoices", modelFields(t, &Invoice{})); err != nil {
+		t.Fatal(err)
+	}
+	if !q.HasColumn("invoices", "number") {
+		t.Error("expected column number")
+	}
+	c, err := q.ColumnComment("invoices", "number")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if c != "printed on the invoice" {
+		t.Errorf("expected printed on the invoice got %s", c)
+	}
+	c, err = q.ColumnComment("invoices", "total")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if c != "" {
+		t.Errorf("expected no comment got %s", c)
+	}
+}
+
+func TestQL_CreateTableIfNotExists(t *testing.T) {
+	q := openMemory(t)
+	fields := modelFields(t, &Invoice{})
+	for i := 0; i < 2; i++ {
+		if err := q.CreateTableIfNotExists("invoices", fields); err != nil {
+			t.Fatalf("call %d: %v", i+1, err)
+		}
+	}
+	n, err := q.ScalarInt64("SELECT count() FROM __Table WHERE Name == $1", "invoices")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if n != 1 {
+		t.Errorf("expected the table once got %d", n)
+	}
+	if err = q.CreateTable("invoices", fields); err == nil {
+		t.Error("expected CreateTable to fail for an existing table")
+	}
+}
@@ -0,0 +1,149 @@
+package ql
+
+import (
+	"fmt"
+	"regexp"
+	"strings"
+)
+
+// The condition helpers build WHERE clause fragments. Each takes the index of
+// the first placeholder it may use, so fragments can be combined into one
+// statement, and returns the fragment, its arguments and the index of the next
+// free placeholder.
+
+// CaseInsensitiveEq builds a condition matching the rows where column equals
+// value ignoring case. ql compares strings byte wise and has no lower case
+// function, but its LIKE operator matches a regular expression, so the value
+// is matched as a case insensitive anchored literal.
+func (q *QL) CaseInsensitiveEq(column string, startIndex int, value string) (sql string, args []interface{}, nextIndex int) {
+	sql = fmt.Sprintf("%s LIKE %s", q.Quote(column), q.BindVar(startIndex))
+	args = []interface{}{"(?i)^" + regexp.QuoteMeta(value) + "$"}
+	return sql, args, startIndex + 1
+}
+
+// OrderClause is one term of an ORDER BY clause.
+type OrderClause struct {
+	// Column is the column to order by. The id() pseudo column is accepted
+	// as is.
+	Column string
+
+	// PrimaryKey orders by the key of the record instead of Column. ql has
+	// no primary keys, records are identified by id().
+	PrimaryKey bool
+
+	// Desc orders in descending order.
+	Desc bool
+}
+
+// OrderBySQL returns the ORDER BY clause for clauses, or an empty string when
+// there are none.
+func (q *QL) OrderBySQL(clauses []OrderClause) string {
+	if len(clauses) == 0 {
+		return ""
+	}
+	terms := make([]string, len(clauses))
+	for i, c := range clauses {
+		column := "id()"
+		if !c.PrimaryKey && c.Column != "id()" {
+			column = q.Quote(c.Column)
+		}
+		dir := "ASC"
+		if c.Desc {
+			dir = "DESC"
+		}
+		terms[i] = column + " " + dir
+	}
+	return "ORDER BY " + strings.Join(terms, ", ")
+}
+
+// PaginateByID returns the query for the page of at most limit records of
+// tableName that follow the record whose id() is afterID, in id() order. Pass
+// 0 for the first page and the last id() of a page for the next one. Unlike
+// OFFSET this does not scan the skipped records.
+//
+// The query selects id() followed by columns. ql does not allow mixing * with
+// other expressions, so the columns have to be named.
+func (q *QL) PaginateByID(tableName string, afterID int64, limit int, columns ...string) (sql string, args []interface{}) {
+	fields := []string{"id()"}
+	for _, c := range columns {
+		fields = append(fields, q.Quote(c))
+	}
+	sql = fmt.Sprintf("SELECT %s FROM %s WHERE id() > %s ORDER BY id() LIMIT %s",
+		strings.Join(fields, ", "), q.Quote(tableName), q.BindVar(1), q.BindVar(2))
+	return sql, []interface{}{afterID, limit}
+}
+
+// ClampLimitOffset bounds user supplied pagination values before they are
+// handed to LimitAndOffsetSQL, which silently ignores the ones it can not use.
+// limit is clamped to [0, maxLimit] and offset to [0, ∞). Note that a limit of
+// 0 renders no LIMIT clause at all.
+func ClampLimitOffset(limit, offset, maxLimit int) (int, int) {
+	if maxLimit < 0 {
+		maxLimit = 0
+	}
+	if limit < 0 {
+		limit = 0
+	}
+	if limit > maxLimit {
+		limit = maxLimit
+	}
+	if offset < 0 {
+		offset = 0
+	}
+	return limit, offset
+}
+
+// BlobEq returns the condition matching the records whose column holds exactly
+// value, with value bound as the argument at startIndex. The value is encoded
+// like BindBlob does so the condition also works for blobs stored as base64,
+// but its size is never checked since nothing is stored. A nil value
+// matches NULL and takes no argument.
+func (q *QL) BlobEq(column string, startIndex int, value []byte) (sql string, args []interface{}, nextIndex int) {
+	if value == nil {
+		return q.Quote(column) + " IS NULL", nil, startIndex
+	}
+	sql = fmt.Sprintf("%s == %s", q.Quote(column), q.BindVar(startIndex))
+	return sql, []interface{}{q.encodeBlob(value)}, startIndex + 1
+}
+
+// BetweenSQL returns the condition matching the records whose column lies
+// between lo and hi, both included, with lo and hi bound as the arguments at
+// startIndex and startIndex+1. It works for any ordered type, like numbers,
+// strings and time.
+func (q *QL) BetweenSQL(column string, startIndex int, lo, hi interface{}) (sql string, args []interface{}, nextIndex int) {
+	sql = fmt.Sprintf("%s BETWEEN %s AND %s", q.Quote(column), q.BindVar(startIndex), q.BindVar(startIndex+1))
+	return sql, []interface{}{lo, hi}, startIndex + 2
+}
+
+// Condition is a WHERE clause fragment together with its arguments.
+type Condition struct {
+	SQL  string
+	Args []interface{}
+}
+
+// ChunkedIN splits values into conditions matching the records whose column
+// is one of at most chunkSize values each, so a long list can be queried in
+// several statements. Every condition numbers its placeholders from $1 to be
+// executed on its own. A chunkSize below 1 puts all values in one condition,
+// no values give no conditions.
+func (q *QL) ChunkedIN(column string, values []interface{}, chunkSize int) []Condition {
+	if chunkSize < 1 {
+		chunkSize = len(values)
+	}
+	var o []Condition
+	for start := 0; start < len(values); start += chunkSize {
+		end := start + chunkSize
+		if end > len(values) {
+			end = len(values)
+		}
+		vars := make([]string, end-start)
+		for i := range vars {
+			vars[i] = q.BindVar(i + 1)
+		}
+		o = append(o, Condition{
+			SQL:  fmt.Sprintf("%s IN (%s)", q.Quote(column), strings.Join(vars, ", ")),
+			Args: values[start:end:end],
+		})
+	}
+	return o
+}
@@ -0,0 +1,290 @@
+package ql
+
+import (
+	"fmt"
+	"reflect"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestQL_CaseInsensitiveEq(t *testing.T) {
+	q := openMemory(t)
+	execTx(t, q.db, "CREATE TABLE users (name string, city string)")
+	execTx(t, q.db, `INSERT INTO users VALUES ("Gernest", "Mwanza"), ("GERNEST", "Arusha"), ("gernest.x", "Dodoma"), ("ernest", "Mwanza")`)
+
+	s, args, next := q.CaseInsensitiveEq("name", 2, "gernest")
+	exp := "name LIKE $2"
+	if s != exp {
+		t.Errorf("expected %s got %s", exp, s)
+	}
+	if next != 3 {
+		t.Errorf("expected 3 got %d", next)
+	}
+	var count int
+	query := "SELECT count() FROM users WHERE city != $1 && " + s
+	err := q.db.QueryRow(query, append([]interface{}{"Dodoma"}, args...)...).Scan(&count)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if count != 2 {
+		t.Errorf("expected 2 got %d", count)
+	}
+
+	// regular expression meta characters are matched literally
+	s, args, _ = q.CaseInsensitiveEq("name", 1, "GERNEST.X")
+	err = q.db.QueryRow("SELECT count() FROM users WHERE "+s, args...).Scan(&count)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if count != 1 {
+		t.Errorf("expected 1 got %d", count)
+	}
+}
+
+type bracketBuilder struct {
+	DefaultBuilder
+}
+
+func (bracketBuilder) Quote(key string) string {
+	return "[" + key + "]"
+}
+
+func TestQL_OrderBySQL(t *testing.T) {
+	q := Memory()
+	o := q.OrderBySQL([]OrderClause{
+		{PrimaryKey: true, Desc: true},
+		{Column: "Name"},
+	})
+	exp := "ORDER BY id() DESC, Name ASC"
+	if o != exp {
+		t.Errorf("expected %s got %s", exp, o)
+	}
+	if o = q.OrderBySQL(nil); o != "" {
+		t.Errorf("expected an empty string got %s", o)
+	}
+
+	q.SetSQLBuilder(bracketBuilder{})
+	o = q.OrderBySQL([]OrderClause{
+		{Column: "id()"},
+		{Column: "Name", Desc: true},
+	})
+	exp = "ORDER BY id() ASC, [Name] DESC"
+	if o != exp {
+		t.Errorf("expected %s got %s", exp, o)
+	}
+}
+
+func TestQL_PaginateByID(t *testing.T) {
+	q := openMemory(t)
+	s, args := q.PaginateByID("Items", 42, 10, "Qty")
+	exp := "SELECT id(), Qty FROM Items WHERE id() > $1 ORDER BY id() LIMIT $2"
+	if s != exp {
+		t.Errorf("expected %s got %s", exp, s)
+	}
+	if !reflect.DeepEqual(args, []interface{}{int64(42), 10}) {
+		t.Errorf("expected [42 10] got %v", args)
+	}
+
+	execTx(t, q.db, migration)
+	tx, err := q.db.Begin()
+	if err != nil {
+		t.Fatal(err)
+	}
+	for i := 0; i < 25; i++ {
+		if _, err = tx.Exec("INSERT INTO Items VALUES (1, 1, $1)", int64(i)); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if err = tx.Commit(); err != nil {
+		t.Fatal(err)
+	}
+	seen := make(map[int64]bool)
+	var after int64
+	pages := 0
+	for {
+		s, args = q.PaginateByID("Items", after, 10, "Qty")
+		rows, err := q.db.Query(s, args...)
+		if err != nil {
+			t.Fatal(err)
+		}
+		n := 0
+		for rows.Next() {
+			var id, qty int64
+			if err = rows.Scan(&id, &qty); err != nil {
+				t.Fatal(err)
+			}
+			if seen[qty] {
+				t.Errorf("record %d returned twice", qty)
+			}
+			seen[qty] = true
+			after = id
+			n++
+		}
+		_ = rows.Close()
+		if n == 0 {
+			break
+		}
+		pages++
+	}
+	if len(seen) != 25 || pages != 3 {
+		t.Errorf("expected 25 records on 3 pages got %d on %d", len(seen), pages)
+	}
+}
+
+func TestClampLimitOffset(t *testing.T) {
+	sample := []struct {
+		limit, offset, max  int
+		expLimit, expOffset int
+	}{
+		{-5, -10, 100, 0, 0},
+		{0, 0, 100, 0, 0},
+		{20, 40, 100, 20, 40},
+		{100, 1, 100, 100, 1},
+		{1000000, 5, 100, 100, 5},
+		{10, 0, -1, 0, 0},
+	}
+	for _, v := range sample {
+		l, o := ClampLimitOffset(v.limit, v.offset, v.max)
+		if l != v.expLimit || o != v.expOffset {
+			t.Errorf("%d, %d, %d: expected %d, %d got %d, %d",
+				v.limit, v.offset, v.max, v.expLimit, v.expOffset, l, o)
+		}
+	}
+}
+
+func TestQL_BlobEq(t *testing.T) {
+	q := openMemory(t)
+	execTx(t, q.db, "CREATE TABLE files (name string, hash blob)")
+	sum := []byte{0xde, 0xad, 0x00, 0xbe, 0xef}
+	execTx(t, q.db, `INSERT INTO files VALUES ("a", $1), ("b", $2), ("c", NULL)`, sum, []byte{0xde, 0xad})
+	find := func(value []byte) []string {
+		cond, args, next := q.BlobEq("hash", 1, value)
+		if value != nil && next != 2 {
+			t.Errorf("expected next index 2 got %d", next)
+		}
+		rows, err := q.db.Query("SELECT name FROM files WHERE "+cond, args...)
+		if err != nil {
+			t.Fatal(err)
+		}
+		defer func() {
+			_ = rows.Close()
+		}()
+		var names []string
+		for rows.Next() {
+			var s string
+			if err = rows.Scan(&s); err != nil {
+				t.Fatal(err)
+			}
+			names = append(names, s)
+		}
+		return names
+	}
+	if o := find(sum); !reflect.DeepEqual(o, []string{"a"}) {
+		t.Errorf("expected [a] got %v", o)
+	}
+	if o := find([]byte{0xde, 0xad, 0x00}); len(o) != 0 {
+		t.Errorf("expected no match got %v", o)
+	}
+	if o := find(nil); !reflect.DeepEqual(o, []string{"c"}) {
+		t.Errorf("expected [c] got %v", o)
+	}
+	s, _, _ := q.BlobEq("hash", 3, sum)
+	if s != "hash == $3" {
+		t.Errorf("expected hash == $3 got %s", s)
+	}
+}
+
+func TestQL_BetweenSQL(t *testing.T) {
+	q := openMemory(t)
+	execTx(t, q.db, migration)
+	day := func(d int) time.Time {
+		return time.Date(2017, 3, d, 0, 0, 0, 0, time.UTC)
+	}
+	execTx(t, q.db, "INSERT INTO Orders VALUES (1, $1), (2, $2), (3, $3), (4, $4)", day(1), day(2), day(3), day(4))
+	find := func(cond string, args ...interface{}) []int64 {
+		rows, err := q.db.Query("SELECT CustomerID FROM Orders WHERE "+cond+" ORDER BY CustomerID", args...)
+		if err != nil {
+			t.Fatal(err)
+		}
+		defer func() {
+			_ = rows.Close()
+		}()
+		var ids []int64
+		for rows.Next() {
+			var id int64
+			if err = rows.Scan(&id); err != nil {
+				t.Fatal(err)
+			}
+			ids = append(ids, id)
+		}
+		return ids
+	}
+
+	cond, args, next := q.BetweenSQL("Date", 1, day(2), day(3))
+	if cond != "Date BETWEEN $1 AND $2" {
+		t.Errorf("expected Date BETWEEN $1 AND $2 got %s", cond)
+	}
+	if next != 3 {
+		t.Errorf("expected next index 3 got %d", next)
+	}
+	if o := find(cond, args...); !reflect.DeepEqual(o, []int64{2, 3}) {
+		t.Errorf("expected [2 3] got %v", o)
+	}
+
+	cond, args, next = q.BetweenSQL("CustomerID", 2, int64(3), int64(10))
+	if cond != "CustomerID BETWEEN $2 AND $3" {
+		t.Errorf("expected CustomerID BETWEEN $2 AND $3 got %s", cond)
+	}
+	if next != 4 {
+		t.Errorf("expected next index 4 got %d", next)
+	}
+	args = append([]interface{}{day(4)}, args...)
+	if o := find("Date < $1 && "+cond, args...); !reflect.DeepEqual(o, []int64{3}) {
+		t.Errorf("expected [3] got %v", o)
+	}
+}
+
+func TestQL_ChunkedIN(t *testing.T) {
+	q := openMemory(t)
+	values := make([]interface{}, 250)
+	for i := range values {
+		values[i] = int64(i)
+	}
+	chunks := q.ChunkedIN("OrderID", values, 100)
+	if len(chunks) != 3 {
+		t.Fatalf("expected 3 chunks got %d", len(chunks))
+	}
+	for i, size := range []int{100, 100, 50} {
+		c := chunks[i]
+		if len(c.Args) != size {
+			t.Errorf("chunk %d: expected %d args got %d", i, size, len(c.Args))
+		}
+		if c.Args[0] != int64(i*100) {
+			t.Errorf("chunk %d: expected first value %d got %v", i, i*100, c.Args[0])
+		}
+		if !strings.HasPrefix(c.SQL, "OrderID IN ($1, $2, ") {
+			t.Errorf("chunk %d: unexpected condition %s", i, c.SQL)
+		}
+		if last := fmt.Sprintf("$%d)", size); !strings.HasSuffix(c.SQL, ", "+last) {
+			t.Errorf("chunk %d: expected the condition to end with %s got %s", i, last, c.SQL)
+		}
+	}
+
+	execTx(t, q.db, migration)
+	execTx(t, q.db, "INSERT INTO Items (OrderID) VALUES (1), (120), (249), (300)")
+	var found int
+	for _, c := range chunks {
+		n, err := q.ScalarInt64("SELECT count() FROM Items WHERE "+c.SQL, c.Args...)
+		if err != nil {
+			t.Fatal(err)
+		}
+		found += int(n)
+	}
+	if found != 3 {
+		t.Errorf("expected 3 matches got %d", found)
+	}
+	if o := q.ChunkedIN("OrderID", nil, 100); len(o) != 0 {
+		t.Errorf("expected no chunks got %v", o)
+	}
+}
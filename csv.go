@@ -0,0 +1,174 @@
+package ql
+
+import (
+	"encoding/base64"
+	"encoding/csv"
+	"fmt"
+	"io"
+	"math/big"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// ExportCSV writes all records of tableName to w as CSV, in id() order. The
+// first line holds the column names, id() is not exported.
+//
+// Records are streamed from the database. Values are written as text: blobs
+// base64 encoded, time in RFC 3339 format, bigrat as a fraction like 1/3, or
+// as a decimal number when set with SetExportRatPlaces, and NULL as an empty
+// field.
+func (q *QL) ExportCSV(tableName string, w io.Writer) error {
+	cols, err := q.ListColumns(tableName)
+	if err != nil {
+		return err
+	}
+	if len(cols) == 0 {
+		return fmt.Errorf("ql: table %s does not exist", tableName)
+	}
+	cw := csv.NewWriter(w)
+	record := make([]string, len(cols))
+	for i, c := range cols {
+		record[i] = c.Name
+	}
+	if err = cw.Write(record); err != nil {
+		return err
+	}
+	err = q.eachRow(tableName, cols, func(_ int64, values []interface{}) error {
+		for i, v := range values {
+			v, err := q.exportValue(tableName, cols[i], v)
+			if err != nil {
+				return err
+			}
+			record[i] = formatCSV(v)
+		}
+		return cw.Write(record)
+	})
+	if err != nil {
+		return err
+	}
+	cw.Flush()
+	return cw.Error()
+}
+
+// formatCSV returns the text of the value v as decoded by decodeValue.
+func formatCSV(v interface{}) string {
+	switch x := v.(type) {
+	case nil:
+		return ""
+	case []byte:
+		return base64.StdEncoding.EncodeToString(x)
+	case time.Time:
+		return x.Format(time.RFC3339Nano)
+	case *big.Rat:
+		return x.String()
+	}
+	return fmt.Sprint(v)
+}
+
+// ImportCSV reads a CSV from r, whose first line holds column names of
+// tableName, and inserts a record for each of the following lines. It returns
+// the number of inserted records.
+//
+// Fields are converted to the type of their column, reading them the way
+// ExportCSV writes them. An empty field is NULL, except in string columns.
+// All records are inserted in a single transaction, a field that can not be
+// converted fails the import with an error naming its line and column, and
+// nothing is inserted.
+func (q *QL) ImportCSV(tableName string, r io.Reader) (int, error) {
+	records, err := csv.NewReader(r).ReadAll()
+	if err != nil {
+		return 0, err
+	}
+	if len(records) == 0 {
+		return 0, fmt.Errorf("ql: import into %s: missing CSV header", tableName)
+	}
+	if err = q.ensureTable(tableName); err != nil {
+		return 0, err
+	}
+	header := records[0]
+	types := make([]string, len(header))
+	vars := make([]string, len(header))
+	names := make([]string, len(header))
+	for i, name := range header {
+		if types[i], err = q.ColumnType(tableName, name); err != nil {
+			return 0, err
+		}
+		vars[i] = q.bindExpr(types[i], i+1)
+		names[i] = q.Quote(name)
+	}
+	query := fmt.Sprintf("INSERT INTO %s (%s) VALUES (%s)",
+		q.Quote(tableName), strings.Join(names, ", "), strings.Join(vars, ", "))
+	n := 0
+	err = q.transaction(func(tx queryer) error {
+		n = 0
+		if err := q.checkRowLimit(tx, tableName, len(records)-1); err != nil {
+			return err
+		}
+		for i, record := range records[1:] {
+			line := i + 2
+			args := make([]interface{}, len(record))
+			for j, field := range record {
+				v, err := parseCSV(types[j], field)
+				if err == nil {
+					v, err = q.bindArg(tableName, header[j], v)
+				}
+				if err != nil {
+					return fmt.Errorf("ql: line %d, column %s: %v", line, header[j], err)
+				}
+				args[j] = v
+			}
+			if _, err := tx.Exec(query, args...); err != nil {
+				return fmt.Errorf("ql: line %d: %v", line, err)
+			}
+			n++
+		}
+		return nil
+	})
+	if err != nil {
+		return 0, err
+	}
+	return n, nil
+}
+
+// parseCSV converts the field s to a value for a column of type typ.
+func parseCSV(typ, s string) (interface{}, error) {
+	typ = canonicalType(typ)
+	if typ == "string" {
+		return s, nil
+	}
+	if s == "" {
+		return nil, nil
+	}
+	if t, ok := numericTypes[typ]; ok {
+		switch t.family {
+		case familySigned:
+			return strconv.ParseInt(s, 10, t.bits)
+		case familyUnsigned:
+			return strconv.ParseUint(s, 10, t.bits)
+		case familyFloat:
+			return strconv.ParseFloat(s, 64)
+		case familyComplex:
+			return strconv.ParseComplex(s, 128)
+		}
+	}
+	switch typ {
+	case "bool":
+		return strconv.ParseBool(s)
+	case "blob":
+		return base64.StdEncoding.DecodeString(s)
+	case "time":
+		return time.Parse(time.RFC3339Nano, s)
+	case "duration":
+		return time.ParseDuration(s)
+	case "bigint":
+		if i, ok := new(big.Int).SetString(s, 10); ok {
+			return i, nil
+		}
+	case "bigrat":
+		if r, ok := new(big.Rat).SetString(s); ok {
+			return r, nil
+		}
+	}
+	return nil, fmt.Errorf("invalid %s %q", typ, s)
+}
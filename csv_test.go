@@ -0,0 +1,109 @@
+package ql
+
+import (
+	"bytes"
+	"encoding/base64"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestQL_ExportCSV(t *testing.T) {
+	q := openMemory(t)
+	execTx(t, q.db, "CREATE TABLE files (name string, data blob, created time, ratio bigrat, size int)")
+	now := time.Date(2017, 3, 1, 10, 30, 0, 0, time.UTC)
+	execTx(t, q.db, "INSERT INTO files VALUES ($1, $2, $3, bigrat($4), $5)", "a, b.txt", []byte("hello"), now, "1/3", int64(5))
+	execTx(t, q.db, "INSERT INTO files (name) VALUES ($1)", "c.txt")
+
+	var b bytes.Buffer
+	if err := q.ExportCSV("files", &b); err != nil {
+		t.Fatal(err)
+	}
+	exp := strings.Join([]string{
+		"name,data,created,ratio,size",
+		`"a, b.txt",` + base64.StdEncoding.EncodeToString([]byte("hello")) + ",2017-03-01T10:30:00Z,1/3,5",
+		"c.txt,,,,",
+		"",
+	}, "\n")
+	if b.String() != exp {
+		t.Errorf("expected\n%s\ngot\n%s", exp, b.String())
+	}
+
+	b.Reset()
+	q.SetExportRatPlaces(2)
+	if err := q.ExportCSV("files", &b); err != nil {
+		t.Fatal(err)
+	}
+	exp = strings.Replace(exp, ",1/3,", ",0.33,", 1)
+	if b.String() != exp {
+		t.Errorf("expected\n%s\ngot\n%s", exp, b.String())
+	}
+	if err := q.ExportCSV("missing", &b); err == nil {
+		t.Error("expected an error")
+	}
+}
+
+func TestQL_ImportCSV(t *testing.T) {
+	q := openMemory(t)
+	execTx(t, q.db, "CREATE TABLE files (name string, data blob, created time, size int)")
+	in := strings.Join([]string{
+		"size,name,created,data",
+		"5,a.txt,2017-03-01T10:30:00Z," + base64.StdEncoding.EncodeToString([]byte("hello")),
+		",b.txt,,",
+	}, "\n")
+	n, err := q.ImportCSV("files", strings.NewReader(in))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if n != 2 {
+		t.Errorf("expected 2 got %d", n)
+	}
+	rows, err := q.ExportRows("files")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(rows) != 2 {
+		t.Fatalf("expected 2 rows got %d", len(rows))
+	}
+	a := rows[0]
+	if a["name"] != "a.txt" || a["size"] != int64(5) {
+		t.Errorf("unexpected row %v", a)
+	}
+	if b, ok := a["data"].([]byte); !ok || !bytes.Equal(b, []byte("hello")) {
+		t.Errorf("expected hello got %v", a["data"])
+	}
+	now := time.Date(2017, 3, 1, 10, 30, 0, 0, time.UTC)
+	if c, ok := a["created"].(time.Time); !ok || !c.Equal(now) {
+		t.Errorf("expected %v got %v", now, a["created"])
+	}
+	b := rows[1]
+	if b["name"] != "b.txt" || b["size"] != nil || b["data"] != nil || b["created"] != nil {
+		t.Errorf("expected NULL values got %v", b)
+	}
+}
+
+func TestQL_ImportCSVRollback(t *testing.T) {
+	q := openMemory(t)
+	execTx(t, q.db, "CREATE TABLE files (name string, size int)")
+	in := "name,size\na.txt,1\nb.txt,big\n"
+	n, err := q.ImportCSV("files", strings.NewReader(in))
+	if err == nil {
+		t.Fatal("expected an error")
+	}
+	if !strings.Contains(err.Error(), "line 3, column size") {
+		t.Errorf("expected the line and column in %v", err)
+	}
+	if n != 0 {
+		t.Errorf("expected 0 got %d", n)
+	}
+	c, err := q.CountRows("files")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if c != 0 {
+		t.Errorf("expected 0 got %d", c)
+	}
+	if _, err = q.ImportCSV("files", strings.NewReader("owner\nroot\n")); err == nil {
+		t.Error("expected an error for an unknown column")
+	}
+}
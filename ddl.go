@@ -0,0 +1,358 @@
+package ql
+
+import (
+	"database/sql"
+	"errors"
+	"fmt"
+	"regexp"
+	"strings"
+
+	"github.com/akamajoris/ngorm/model"
+	"github.com/akamajoris/ngorm/util"
+)
+
+// ColumnName returns the name of the ql column that stores field.
+//
+// ngorm resolves the name when it parses the model, taking the column tag and
+// the embedded prefix into account, and the result is used as is. Fields that
+// were built by hand fall back to the column tag and then to the snake cased
+// Go name, which is what ngorm would have chosen.
+func (q *QL) ColumnName(field *model.StructField) string {
+	if field.DBName != "" {
+		return field.DBName
+	}
+	if name, ok := field.TagSettings["COLUMN"]; ok && name != "" {
+		return name
+	}
+	return util.ToDBName(field.Name)
+}
+
+// CreateTableSQL returns the CREATE TABLE statement for a table with the
+// given fields. Only normal fields are stored, relationships and ignored fields
+// are skipped.
+func (q *QL) CreateTableSQL(tableName string, fields []*model.StructField) (string, error) {
+	var cols []string
+	for _, field := range fields {
+		if !field.IsNormal || field.IsIgnored {
+			continue
+		}
+		typ, err := q.DataTypeOf(field)
+		if err != nil {
+			return "", err
+		}
+		cols = append(cols, q.Quote(q.ColumnName(field))+" "+typ)
+	}
+	if len(cols) == 0 {
+		return "", errors.New("ql: a table needs at least one column")
+	}
+	return fmt.Sprintf("CREATE TABLE %s (%s)", q.Quote(tableName), strings.Join(cols, ", ")), nil
+}
+
+// HasFieldColumn is like HasColumn but takes the model field instead of the
+// column name.
+func (q *QL) HasFieldColumn(tableName string, field *model.StructField) bool {
+	return q.HasColumn(tableName, q.ColumnName(field))
+}
+
+// identifierRe matches the names ql accepts for tables, columns and indexes.
+var identifierRe = regexp.MustCompile(`^[A-Za-z_][A-Za-z0-9_]*$`)
+
+// ValidateTableName returns an error if name can not be used as a ql table
+// name. A ql database is a single file with no notion of other databases or
+// schemas, so qualified names like otherdb.table are rejected up front instead
+// of failing later with a confusing parse error.
+func (q *QL) ValidateTableName(name string) error {
+	if strings.Contains(name, ".") {
+		return fmt.Errorf("ql: qualified table name %q, ql has no cross database queries", name)
+	}
+	if !identifierRe.MatchString(name) {
+		return fmt.Errorf("ql: invalid table name %q", name)
+	}
+	return nil
+}
+
+// DropTablesForModels returns a script that drops tableNames in a single
+// transaction, it needs no database so it can be generated ahead of time.
+//
+// Tables are expected in the order they were created, so they are dropped in
+// reverse to remove the tables that refer to others first. ql drops the
+// indexes of a table together with it. Tables that do not exist are skipped
+// when the script runs.
+func (q *QL) DropTablesForModels(tableNames []string) (string, error) {
+	if len(tableNames) == 0 {
+		return "", errors.New("ql: no tables to drop")
+	}
+	seen := make(map[string]bool)
+	var b strings.Builder
+	b.WriteString("BEGIN TRANSACTION;\n")
+	for i := len(tableNames) - 1; i >= 0; i-- {
+		name := tableNames[i]
+		if err := q.ValidateTableName(name); err != nil {
+			return "", err
+		}
+		if seen[name] {
+			continue
+		}
+		seen[name] = true
+		fmt.Fprintf(&b, "\tDROP TABLE IF EXISTS %s;\n", q.Quote(name))
+	}
+	b.WriteString("COMMIT;")
+	return b.String(), nil
+}
+
+// TableDef describes a table of a migration script.
+type TableDef struct {
+	Name   string
+	Fields []*model.StructField
+
+	// Indexes lists the column sets to index. The indexes are named after the
+	// table and the columns, like the ones created by EnsureExpectedIndexes.
+	Indexes [][]string
+}
+
+// SetAutoCreateTables makes the helpers that insert records, like ExecInsert,
+// BatchInsert and ImportRows, create the table they write to from its entry of
+// models, together with its indexes, when it does not exist yet. The table is
+// created in the transaction of the insert. This is meant for prototyping, nil
+// turns it off, which is the default.
+func (q *QL) SetAutoCreateTables(models []TableDef) {
+	if models == nil {
+		q.autoCreateTables = nil
+		return
+	}
+	q.autoCreateTables = make(map[string]TableDef)
+	for _, t := range models {
+		q.autoCreateTables[t.Name] = t
+	}
+}
+
+// autoCreateTable creates tableName in tx when it is missing and is one of
+// the tables set with SetAutoCreateTables.
+func (q *QL) autoCreateTable(tx queryer, tableName string) error {
+	t, ok := q.autoCreateTables[tableName]
+	if !ok {
+		return nil
+	}
+	query, err := q.CreateTableSQL(t.Name, t.Fields)
+	if err != nil {
+		return err
+	}
+	created, err := q.createTableTx(tx, query, t.Name, t.Fields, true)
+	if err != nil || !created {
+		return err
+	}
+	for _, columns := range t.Indexes {
+		i := q.newIndex(t.Name, q.IndexName(t.Name, columns), columns, false)
+		if _, err = tx.Exec(createIndexSQL(i)); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// ensureTable creates tableName, in a transaction of its own, when it is
+// missing and is one of the tables set with SetAutoCreateTables.
+func (q *QL) ensureTable(tableName string) error {
+	if _, ok := q.autoCreateTables[tableName]; !ok {
+		return nil
+	}
+	return q.transaction(func(tx queryer) error {
+		return q.autoCreateTable(tx, tableName)
+	})
+}
+
+// MigrationScript returns a script that creates tables, in order, together
+// with their indexes in a single transaction. Like DropTablesForModels it
+// needs no database.
+func (q *QL) MigrationScript(tables []TableDef) (string, error) {
+	if len(tables) == 0 {
+		return "", errors.New("ql: no tables to create")
+	}
+	var b strings.Builder
+	b.WriteString("BEGIN TRANSACTION;\n")
+	for _, t := range tables {
+		if err := q.ValidateTableName(t.Name); err != nil {
+			return "", err
+		}
+		create, err := q.CreateTableSQL(t.Name, t.Fields)
+		if err != nil {
+			return "", fmt.Errorf("ql: table %s: %v", t.Name, err)
+		}
+		fmt.Fprintf(&b, "\t%s;\n", create)
+		for _, columns := range t.Indexes {
+			if len(columns) == 0 {
+				return "", fmt.Errorf("ql: table %s: index has no columns", t.Name)
+			}
+			i := q.newIndex(t.Name, q.IndexName(t.Name, columns), columns, false)
+			fmt.Fprintf(&b, "\t%s;\n", createIndexSQL(i))
+		}
+	}
+	b.WriteString("COMMIT;")
+	return b.String(), nil
+}
+
+// renameScratchColumn is the column RenameColumn adds and drops again to make
+// ql reload the constraints and defaults of the table.
+const renameScratchColumn = "__ql_rename"
+
+// RenameColumn renames the column oldName of tableName to newName. ql can not
+// rename columns so in a single transaction a column newName is added, the
+// values are copied over and oldName is dropped, after which the indexes that
+// used oldName are recreated on newName. Records keep their id(), but the
+// column moves to the end of the record.
+//
+// The default of oldName is kept. ql only allows a column with a NOT NULL or
+// other constraint to be added to an empty table, so renaming such a column of
+// a table that has records fails and leaves the table unchanged.
+func (q *QL) RenameColumn(tableName, oldName, newName string) error {
+	if !identifierRe.MatchString(newName) {
+		return fmt.Errorf("ql: invalid column name %q", newName)
+	}
+	return q.transaction(func(tx queryer) error {
+		cols, err := columns(tx, tableName)
+		if err != nil {
+			return err
+		}
+		var col *Column
+		for k, c := range cols {
+			switch c.Name {
+			case newName:
+				return fmt.Errorf("ql: table %s already has a column %s", tableName, newName)
+			case oldName:
+				col = &cols[k]
+			}
+		}
+		if col == nil {
+			return fmt.Errorf("ql: table %s has no column %s", tableName, oldName)
+		}
+		c, err := columnConstraint(tx, tableName, oldName)
+		if err != nil {
+			return err
+		}
+		rename := regexp.MustCompile(`\b` + regexp.QuoteMeta(oldName) + `\b`)
+		def := newName + " " + col.Type
+		switch {
+		case c.expr != "":
+			def += " " + rename.ReplaceAllString(c.expr, newName)
+		case c.notNull:
+			def += " NOT NULL"
+		}
+		if c.constrained() {
+			n, err := scalarInt64(tx, fmt.Sprintf("SELECT count() FROM %s", tableName))
+			if err != nil {
+				return err
+			}
+			if n > 0 {
+				return fmt.Errorf("ql: column %s of %s has a constraint, ql can not add it back to a table with records", oldName, tableName)
+			}
+		}
+		if c.dflt != "" {
+			def += " DEFAULT " + rename.ReplaceAllString(c.dflt, newName)
+		}
+		idx, err := indexes(tx, tableName)
+		if err != nil {
+			return err
+		}
+		stmts := []string{
+			fmt.Sprintf("ALTER TABLE %s ADD %s", tableName, def),
+			fmt.Sprintf("UPDATE %s %s = %s", tableName, newName, oldName),
+			fmt.Sprintf("ALTER TABLE %s DROP COLUMN %s", tableName, oldName),
+			// ql reloads the constraints and defaults of a table before it
+			// forgets a dropped column, so they only line up with the columns
+			// again after another column at the end is dropped.
+			fmt.Sprintf("ALTER TABLE %s ADD %s bool", tableName, renameScratchColumn),
+			fmt.Sprintf("ALTER TABLE %s DROP COLUMN %s", tableName, renameScratchColumn),
+		}
+		for _, s := range stmts {
+			if _, err = tx.Exec(s); err != nil {
+				return err
+			}
+		}
+		for _, i := range idx {
+			used := false
+			for k, e := range i.Exprs {
+				if rename.MatchString(e) {
+					i.Exprs[k] = rename.ReplaceAllString(e, newName)
+					used = true
+				}
+			}
+			if !used {
+				continue
+			}
+			// Dropping the column dropped the indexes that used it.
+			if _, err = tx.Exec(fmt.Sprintf("DROP INDEX IF EXISTS %s", i.Name)); err != nil {
+				return err
+			}
+			if _, err = tx.Exec(createIndexSQL(i)); err != nil {
+				return err
+			}
+		}
+		return nil
+	})
+}
+
+// constraint holds what ql keeps in __Column2 for a column.
+type constraint struct {
+	notNull bool
+	expr    string
+	dflt    string
+}
+
+// constrained returns true if c restricts the values of its column.
+func (c constraint) constrained() bool {
+	return c.notNull || c.expr != ""
+}
+
+// columnConstraint returns the constraint and default of column of tableName.
+// ql only creates the __Column2 table once a column has one of them.
+func columnConstraint(db queryer, tableName, column string) (constraint, error) {
+	var c constraint
+	n, err := scalarInt64(db, "select count() from __Table where Name=$1", "__Column2")
+	if err != nil || n == 0 {
+		return c, err
+	}
+	err = db.QueryRow("select NotNull, ConstraintExpr, DefaultExpr from __Column2 where TableName=$1 && Name=$2",
+		tableName, column).Scan(&c.notNull, &c.expr, &c.dflt)
+	if err == sql.ErrNoRows {
+		err = nil
+	}
+	return c, err
+}
+
+// ValidateIDField reports the first of fields that looks like a primary key,
+// an ID field or one tagged primary_key. ql has no primary keys, records are
+// identified by id() and such a field is stored in a normal column that ql
+// never fills in.
+//
+// The problem is passed as a message to the handler set with SetWarningHandler,
+// if any, and nil is returned. Under SetStrictIDField it is returned as an
+// error instead.
+func (q *QL) ValidateIDField(fields []*model.StructField) error {
+	for _, field := range fields {
+		if field.IsIgnored || !field.IsPrimaryKey && field.Name != "ID" {
+			continue
+		}
+		err := fmt.Errorf("ql: field %s is stored in the normal column %s, ql identifies records with id() and does not set it",
+			field.Name, q.ColumnName(field))
+		if q.strictIDField {
+			return err
+		}
+		if q.warningHandler != nil {
+			q.warningHandler(err.Error())
+		}
+		return nil
+	}
+	return nil
+}
+
+// SetStrictIDField makes ValidateIDField return an error for a primary key
+// field instead of reporting a warning.
+func (q *QL) SetStrictIDField(ok bool) {
+	q.strictIDField = ok
+}
+
+// SetWarningHandler sets the function called with the problems the dialect
+// notices but does not treat as errors, like the ones of ValidateIDField.
+func (q *QL) SetWarningHandler(fn func(message string)) {
+	q.warningHandler = fn
+}
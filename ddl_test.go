@@ -0,0 +1,378 @@
+package ql
+
+import (
+	"reflect"
+	"strings"
+	"testing"
+
+	"github.com/akamajoris/ngorm/engine"
+	"github.com/akamajoris/ngorm/model"
+	"github.com/akamajoris/ngorm/scope"
+)
+
+type Address struct {
+	City string
+}
+
+type Customer struct {
+	Name    string `gorm:"column:full_name"`
+	Age     int
+	Address Address `gorm:"embedded;embedded_prefix:home_"`
+}
+
+func modelFields(t *testing.T, value interface{}) []*model.StructField {
+	e := &engine.Engine{
+		Search:    &model.Search{},
+		Scope:     &model.Scope{},
+		StructMap: model.NewStructsMap(),
+	}
+	m, err := scope.GetModelStruct(e, value)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return m.StructFields
+}
+
+func TestQL_ColumnName(t *testing.T) {
+	q := Memory()
+	names := make(map[string]string)
+	for _, f := range modelFields(t, &Customer{}) {
+		names[f.Name] = q.ColumnName(f)
+	}
+	for k, v := range map[string]string{
+		"Name": "full_name",
+		"Age":  "age",
+		"City": "home_city",
+	} {
+		if names[k] != v {
+			t.Errorf("%s: expected %s got %s", k, v, names[k])
+		}
+	}
+
+	// fields that were not parsed by ngorm
+	f := &model.StructField{
+		Name:        "CreatedAt",
+		TagSettings: map[string]string{},
+	}
+	if o := q.ColumnName(f); o != "created_at" {
+		t.Errorf("expected created_at got %s", o)
+	}
+	f.TagSettings["COLUMN"] = "Created"
+	if o := q.ColumnName(f); o != "Created" {
+		t.Errorf("expected Created got %s", o)
+	}
+}
+
+func TestQL_CreateTableSQL(t *testing.T) {
+	q := openMemory(t)
+	fields := modelFields(t, &Customer{})
+	s, err := q.CreateTableSQL("customers", fields)
+	if err != nil {
+		t.Fatal(err)
+	}
+	exp := "CREATE TABLE customers (full_name string, age int, home_city string)"
+	if s != exp {
+		t.Errorf("expected %s got %s", exp, s)
+	}
+	execTx(t, q.db, s)
+	for _, f := range fields {
+		if !q.HasFieldColumn("customers", f) {
+			t.Errorf("expected column for %s", f.Name)
+		}
+	}
+}
+
+func TestQL_DropTablesForModels(t *testing.T) {
+	q := openMemory(t)
+	s, err := q.DropTablesForModels([]string{"Orders", "Items", "Orders"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	exp := "BEGIN TRANSACTION;\n\tDROP TABLE IF EXISTS Orders;\n\tDROP TABLE IF EXISTS Items;\nCOMMIT;"
+	if s != exp {
+		t.Errorf("expected %s got %s", exp, s)
+	}
+	execTx(t, q.db, migration)
+	execTx(t, q.db, s)
+	for _, v := range []string{"Orders", "Items"} {
+		if q.HasTable(v) {
+			t.Errorf("expected %s to be dropped", v)
+		}
+	}
+	if q.HasIndex("Orders", "OrdersDate") {
+		t.Error("expected the indexes to be dropped")
+	}
+	// running the script again is harmless
+	execTx(t, q.db, s)
+
+	if _, err = q.DropTablesForModels(nil); err == nil {
+		t.Error("expected an error")
+	}
+	if _, err = q.DropTablesForModels([]string{"users; DROP TABLE x"}); err == nil {
+		t.Error("expected an error")
+	}
+}
+
+func TestQL_ValidateTableName(t *testing.T) {
+	q := Memory()
+	for _, v := range []string{"customers", "Orders", "_tmp", "a1"} {
+		if err := q.ValidateTableName(v); err != nil {
+			t.Errorf("%s: %v", v, err)
+		}
+	}
+	for _, v := range []string{"otherdb.customers", "a.b.c", ".customers", "", "1st", "my table"} {
+		if err := q.ValidateTableName(v); err == nil {
+			t.Errorf("%q: expected an error", v)
+		}
+	}
+	err := q.ValidateTableName("otherdb.customers")
+	if err == nil || !strings.Contains(err.Error(), "qualified") {
+		t.Errorf("expected a qualified name error got %v", err)
+	}
+}
+
+type Purchase struct {
+	ID    int64
+	Order string
+	Limit int64 `gorm:"column:max_items"`
+	Desc  string
+}
+
+func TestQL_ReservedWordColumns(t *testing.T) {
+	q := Memory()
+	o := q.ReservedWordColumns(modelFields(t, &Purchase{}))
+	exp := []string{"order", "desc"}
+	if !reflect.DeepEqual(o, exp) {
+		t.Errorf("expected %v got %v", exp, o)
+	}
+	if o = q.ReservedWordColumns(modelFields(t, &Customer{})); len(o) != 0 {
+		t.Errorf("expected no columns got %v", o)
+	}
+}
+
+type Author struct {
+	ID   int64
+	Name string
+}
+
+type Book struct {
+	ID       int64
+	AuthorID int64
+	Title    string
+}
+
+func TestQL_MigrationScript(t *testing.T) {
+	q := openMemory(t)
+	s, err := q.MigrationScript([]TableDef{
+		{Name: "authors", Fields: modelFields(t, &Author{}), Indexes: [][]string{{"id()"}}},
+		{Name: "books", Fields: modelFields(t, &Book{}), Indexes: [][]string{{"author_id"}, {"author_id", "title"}}},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	exp := `BEGIN TRANSACTION;
+	CREATE TABLE authors (id int64, name string);
+	CREATE INDEX authors_id_idx ON authors (id());
+	CREATE TABLE books (id int64, author_id int64, title string);
+	CREATE INDEX books_author_id_idx ON books (author_id);
+	CREATE INDEX books_author_id_title_idx ON books (author_id, title);
+COMMIT;`
+	if s != exp {
+		t.Errorf("expected %s got %s", exp, s)
+	}
+	execTx(t, q.db, s)
+	for _, v := range []string{"authors", "books"} {
+		if !q.HasTable(v) {
+			t.Errorf("expected table %s to exist", v)
+		}
+	}
+	if !q.HasIndexOnColumns("books", []string{"author_id", "title"}) {
+		t.Error("expected the compound index to exist")
+	}
+	if _, err = q.MigrationScript(nil); err == nil {
+		t.Error("expected an error")
+	}
+	if _, err = q.MigrationScript([]TableDef{{Name: "other.books", Fields: modelFields(t, &Book{})}}); err == nil {
+		t.Error("expected an error for a qualified name")
+	}
+}
+
+func TestQL_RenameColumn(t *testing.T) {
+	q := openMemory(t)
+	execTx(t, q.db, migration)
+	execTx(t, q.db, "INSERT INTO Items VALUES (1, 10, 100), (2, 20, 200)")
+	var ids []int64
+	rows, err := q.db.Query("SELECT id(), OrderID FROM Items ORDER BY OrderID")
+	if err != nil {
+		t.Fatal(err)
+	}
+	for rows.Next() {
+		var id, order int64
+		if err = rows.Scan(&id, &order); err != nil {
+			t.Fatal(err)
+		}
+		ids = append(ids, id)
+	}
+	_ = rows.Close()
+	if err = q.RenameColumn("Items", "OrderID", "OrderRef"); err != nil {
+		t.Fatal(err)
+	}
+	if q.HasColumn("Items", "OrderID") {
+		t.Error("expected OrderID to be gone")
+	}
+	cols, err := q.ListColumns("Items")
+	if err != nil {
+		t.Fatal(err)
+	}
+	exp := []Column{{"ProductID", "int64"}, {"Qty", "int64"}, {"OrderRef", "int64"}}
+	if !reflect.DeepEqual(cols, exp) {
+		t.Errorf("expected %v got %v", exp, cols)
+	}
+	rows, err = q.db.Query("SELECT id(), OrderRef, Qty FROM Items ORDER BY OrderRef")
+	if err != nil {
+		t.Fatal(err)
+	}
+	var got [][3]int64
+	for rows.Next() {
+		var v [3]int64
+		if err = rows.Scan(&v[0], &v[1], &v[2]); err != nil {
+			t.Fatal(err)
+		}
+		got = append(got, v)
+	}
+	_ = rows.Close()
+	if e := [][3]int64{{ids[0], 1, 100}, {ids[1], 2, 200}}; !reflect.DeepEqual(got, e) {
+		t.Errorf("expected %v got %v", e, got)
+	}
+	if !q.HasIndex("Items", "ItemsOrderID") || !q.HasIndexOnColumns("Items", []string{"OrderRef"}) {
+		t.Error("expected the index to be recreated on the new name")
+	}
+	if err = q.RenameColumn("Items", "Qty", "ProductID"); err == nil {
+		t.Error("expected an error for an existing column")
+	}
+	if err = q.RenameColumn("Items", "Missing", "Other"); err == nil {
+		t.Error("expected an error for a missing column")
+	}
+
+	execTx(t, q.db, `CREATE TABLE Stock (Qty int Qty >= 0, Place string NOT NULL, Unit string DEFAULT "pcs")`)
+	if err = q.RenameColumn("Stock", "Qty", "Count"); err != nil {
+		t.Fatal(err)
+	}
+	if err = q.RenameColumn("Stock", "Unit", "Measure"); err != nil {
+		t.Fatal(err)
+	}
+	execTx(t, q.db, `INSERT INTO Stock (Count, Place) VALUES (1, "a")`)
+	var unit string
+	if err = q.db.QueryRow("SELECT Measure FROM Stock").Scan(&unit); err != nil {
+		t.Fatal(err)
+	}
+	if unit != "pcs" {
+		t.Errorf("expected the default pcs got %q", unit)
+	}
+	tx, err := q.db.Begin()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err = tx.Exec(`INSERT INTO Stock (Count, Place) VALUES (-1, "b")`); err == nil {
+		t.Error("expected the constraint to be kept")
+	}
+	_ = tx.Rollback()
+	if err = q.RenameColumn("Stock", "Place", "Location"); err == nil {
+		t.Error("expected an error for a NOT NULL column of a table with records")
+	}
+	if !q.HasColumn("Stock", "Place") {
+		t.Error("expected Place to be kept")
+	}
+}
+
+type Memo struct {
+	Title string
+	Body  string
+}
+
+func TestQL_ValidateIDField(t *testing.T) {
+	q := Memory()
+	var warnings []string
+	q.SetWarningHandler(func(message string) {
+		warnings = append(warnings, message)
+	})
+	if err := q.ValidateIDField(modelFields(t, &Department{})); err != nil {
+		t.Fatal(err)
+	}
+	if len(warnings) != 1 || !strings.Contains(warnings[0], "ID") {
+		t.Errorf("expected a warning about ID got %v", warnings)
+	}
+	warnings = nil
+	if err := q.ValidateIDField(modelFields(t, &Memo{})); err != nil {
+		t.Fatal(err)
+	}
+	if len(warnings) != 0 {
+		t.Errorf("expected no warnings got %v", warnings)
+	}
+	q.SetStrictIDField(true)
+	if err := q.ValidateIDField(modelFields(t, &Department{})); err == nil {
+		t.Error("expected an error in strict mode")
+	}
+	if err := q.ValidateIDField(modelFields(t, &Memo{})); err != nil {
+		t.Errorf("expected no error got %v", err)
+	}
+	if len(warnings) != 0 {
+		t.Errorf("expected no warnings in strict mode got %v", warnings)
+	}
+}
+
+func TestQL_SetAutoCreateTables(t *testing.T) {
+	q := openMemory(t)
+	q.SetAutoCreateTables([]TableDef{
+		{Name: "authors", Fields: modelFields(t, &Author{})},
+		{Name: "books", Fields: modelFields(t, &Book{}), Indexes: [][]string{{"author_id"}}},
+	})
+	if q.HasTable("books") {
+		t.Fatal("expected books not to exist yet")
+	}
+	values := map[string]interface{}{"author_id": int64(1), "title": "Kufa"}
+	if _, err := q.ExecInsert(q.db, "books", values); err != nil {
+		t.Fatal(err)
+	}
+	if !q.HasTable("books") {
+		t.Error("expected books to be created")
+	}
+	if !q.HasIndexOnColumns("books", []string{"author_id"}) {
+		t.Error("expected the index of books to be created")
+	}
+	if _, err := q.ExecInsert(q.db, "books", values); err != nil {
+		t.Fatal(err)
+	}
+	n, err := q.CountRows("books")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if n != 2 {
+		t.Errorf("expected 2 got %d", n)
+	}
+
+	tx, err := q.db.Begin()
+	if err != nil {
+		t.Fatal(err)
+	}
+	err = q.BatchInsert(&Tx{Tx: tx}, "authors", []string{"name"}, [][]interface{}{{"a"}, {"b"}})
+	if err != nil {
+		_ = tx.Rollback()
+		t.Fatal(err)
+	}
+	if err = tx.Commit(); err != nil {
+		t.Fatal(err)
+	}
+	if n, err = q.CountRows("authors"); err != nil || n != 2 {
+		t.Errorf("expected 2 authors got %d, %v", n, err)
+	}
+
+	if _, err = q.ImportRows("other", []map[string]interface{}{{"name": "x"}}); err == nil {
+		t.Error("expected an error for a table without a definition")
+	}
+	q.SetAutoCreateTables(nil)
+	execTx(t, q.db, "DROP TABLE books")
+	if _, err = q.ExecInsert(q.db, "books", values); err == nil {
+		t.Error("expected an error once the option is turned off")
+	}
+}
@@ -0,0 +1,87 @@
+package ql
+
+import (
+	"fmt"
+	"math/big"
+)
+
+// Decimal is a fixed point number, Units scaled down by Scale decimal digits.
+// Decimal{Units: 12345, Scale: 2} is 123.45.
+//
+// DataTypeOf maps Decimal fields to bigrat columns so values are stored
+// exactly and compare correctly regardless of their scale. Store them with
+// BindDecimal and read them back with ScanDecimal. A field maps to a single
+// column, models that need the units and the scale in separate integer columns
+// have to declare two fields.
+type Decimal struct {
+	Units int64
+	Scale uint8
+}
+
+// Rat returns d as a rational number.
+func (d Decimal) Rat() *big.Rat {
+	den := new(big.Int).Exp(big.NewInt(10), big.NewInt(int64(d.Scale)), nil)
+	return new(big.Rat).SetFrac(big.NewInt(d.Units), den)
+}
+
+// String formats d with exactly Scale decimal digits.
+func (d Decimal) String() string {
+	return d.Rat().FloatString(int(d.Scale))
+}
+
+// Cmp compares d and o by value, like big.Rat.Cmp.
+func (d Decimal) Cmp(o Decimal) int {
+	return d.Rat().Cmp(o.Rat())
+}
+
+// DecimalFromRat returns r as a Decimal with the given scale. An error is
+// returned when r can not be represented exactly with scale digits or does not
+// fit in int64 units.
+func DecimalFromRat(r *big.Rat, scale uint8) (Decimal, error) {
+	den := new(big.Int).Exp(big.NewInt(10), big.NewInt(int64(scale)), nil)
+	units := new(big.Rat).Mul(r, new(big.Rat).SetInt(den))
+	if !units.IsInt() {
+		return Decimal{}, fmt.Errorf("ql: %s can not be represented with %d decimal digits", r.RatString(), scale)
+	}
+	if !units.Num().IsInt64() {
+		return Decimal{}, fmt.Errorf("ql: %s overflows decimal units", r.RatString())
+	}
+	return Decimal{Units: units.Num().Int64(), Scale: scale}, nil
+}
+
+// BindDecimal returns the argument to pass for d. ql does not accept big.Rat
+// arguments so the value is passed as text, which must be converted in the
+// statement, as in
+//
+//	INSERT INTO Prices (Amount) VALUES (bigrat($1))
+func BindDecimal(d Decimal) string {
+	return d.Rat().RatString()
+}
+
+// ScanDecimal decodes src, a value read from a bigrat column, into dst. The
+// scale already set on dst is kept if it represents the value exactly,
+// otherwise the smallest scale that does is used.
+func ScanDecimal(src interface{}, dst *Decimal) error {
+	var s string
+	switch v := src.(type) {
+	case []byte:
+		s = string(v)
+	case string:
+		s = v
+	default:
+		return fmt.Errorf("ql: cannot scan %T into Decimal", src)
+	}
+	r, ok := new(big.Rat).SetString(s)
+	if !ok {
+		return fmt.Errorf("ql: invalid bigrat %q", s)
+	}
+	d, err := DecimalFromRat(r, dst.Scale)
+	for scale := int(dst.Scale) + 1; err != nil && scale <= 18; scale++ {
+		d, err = DecimalFromRat(r, uint8(scale))
+	}
+	if err != nil {
+		return err
+	}
+	*dst = d
+	return nil
+}
@@ -0,0 +1,84 @@
+package ql
+
+import (
+	"math/big"
+	"testing"
+)
+
+type Price struct {
+	ID     int64
+	Amount Decimal
+}
+
+func TestQL_DataTypeOfDecimal(t *testing.T) {
+	q := Memory()
+	for _, f := range modelFields(t, &Price{}) {
+		if f.Name != "Amount" {
+			continue
+		}
+		s, err := q.DataTypeOf(f)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if s != "bigrat" {
+			t.Errorf("expected bigrat got %s", s)
+		}
+	}
+}
+
+func TestDecimal(t *testing.T) {
+	q := openMemory(t)
+	execTx(t, q.db, "CREATE TABLE Prices (Amount bigrat)")
+	values := []Decimal{
+		{Units: 12345, Scale: 2},
+		{Units: -5, Scale: 0},
+		{Units: 1234501, Scale: 4},
+		{Units: 99, Scale: 3},
+	}
+	for _, v := range values {
+		execTx(t, q.db, "INSERT INTO Prices VALUES (bigrat($1))", BindDecimal(v))
+	}
+	rows, err := q.db.Query("SELECT Amount FROM Prices ORDER BY Amount")
+	if err != nil {
+		t.Fatal(err)
+	}
+	var got []Decimal
+	for rows.Next() {
+		var src interface{}
+		if err = rows.Scan(&src); err != nil {
+			t.Fatal(err)
+		}
+		d := Decimal{Scale: 2}
+		if err = ScanDecimal(src, &d); err != nil {
+			t.Fatal(err)
+		}
+		got = append(got, d)
+	}
+	_ = rows.Close()
+	exp := []string{"-5.00", "0.099", "123.45", "123.4501"}
+	if len(got) != len(exp) {
+		t.Fatalf("expected %d values got %d", len(exp), len(got))
+	}
+	for i, v := range exp {
+		if got[i].String() != v {
+			t.Errorf("expected %s got %s", v, got[i])
+		}
+	}
+	if got[2] != values[0] {
+		t.Errorf("expected %#v got %#v", values[0], got[2])
+	}
+	if got[2].Cmp(got[3]) >= 0 {
+		t.Errorf("expected %s to be less than %s", got[2], got[3])
+	}
+
+	if _, err = DecimalFromRat(big.NewRat(1, 3), 2); err == nil {
+		t.Error("expected an error")
+	}
+	d, err := DecimalFromRat(big.NewRat(3, 4), 2)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if d != (Decimal{Units: 75, Scale: 2}) {
+		t.Errorf("expected 0.75 got %s", d)
+	}
+}
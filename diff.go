@@ -0,0 +1,375 @@
+package ql
+
+import (
+	"encoding/json"
+	"fmt"
+	"sort"
+	"strings"
+)
+
+// SchemaDiff lists the differences between two database schemas. It is read as
+// the changes needed to turn the first schema into the second one.
+type SchemaDiff struct {
+	AddedTables   []string
+	RemovedTables []string
+	Columns       []ColumnDiff
+	Indexes       []IndexDiff
+
+	// Reordered lists the tables present in both schemas whose columns are
+	// ordered differently than ALTER TABLE would leave them: the columns
+	// both schemas have are in another order, or an added column comes
+	// before one of them while ALTER TABLE appends added columns.
+	Reordered []string
+}
+
+// ColumnDiff describes a column that differs between two schemas. From is the
+// type in the first schema and To the type in the second one, an empty From
+// means the column was added and an empty To that it was removed.
+type ColumnDiff struct {
+	Table  string
+	Column string
+	From   string
+	To     string
+}
+
+// IndexDiff describes an index that exists in only one of two schemas. An index
+// whose columns or uniqueness changed is reported as removed and added.
+type IndexDiff struct {
+	Table   string
+	Index   string
+	Columns []string
+	Unique  bool
+	Added   bool
+}
+
+// Empty returns true if there are no differences.
+func (d SchemaDiff) Empty() bool {
+	return len(d.AddedTables) == 0 && len(d.RemovedTables) == 0 &&
+		len(d.Columns) == 0 && len(d.Indexes) == 0 && len(d.Reordered) == 0
+}
+
+// CompareSchemas introspects the databases of a and b and reports how the
+// schema of b differs from the one of a. Tables only present in one database
+// are reported as a whole, their columns and indexes are not listed.
+func CompareSchemas(a, b *QL) (SchemaDiff, error) {
+	as, err := a.snapshot()
+	if err != nil {
+		return SchemaDiff{}, err
+	}
+	bs, err := b.snapshot()
+	if err != nil {
+		return SchemaDiff{}, err
+	}
+	return diffSnapshots(as, bs), nil
+}
+
+// snapshot is the schema of a database as serialized by SchemaSnapshot.
+// Tables are ordered by name, columns in record order and indexes by name.
+type snapshot struct {
+	Tables []snapshotTable `json:"tables"`
+}
+
+type snapshotTable struct {
+	Name    string           `json:"name"`
+	Columns []snapshotColumn `json:"columns"`
+	Indexes []snapshotIndex  `json:"indexes"`
+}
+
+type snapshotColumn struct {
+	Name    string `json:"name"`
+	Type    string `json:"type"`
+	NotNull bool   `json:"not_null"`
+}
+
+type snapshotIndex struct {
+	Name    string   `json:"name"`
+	Columns []string `json:"columns"`
+	Unique  bool     `json:"unique"`
+}
+
+// SchemaSnapshot returns the schema of the database as a JSON document, to be
+// kept under version control or compared with DiffSnapshots. It lists the user
+// tables with their columns, their canonical type and whether they are NOT
+// NULL, and their indexes. The same schema always gives the same document.
+func (q *QL) SchemaSnapshot() ([]byte, error) {
+	s, err := q.snapshot()
+	if err != nil {
+		return nil, err
+	}
+	return json.MarshalIndent(s, "", "  ")
+}
+
+// DiffSnapshots is like CompareSchemas for two documents returned by
+// SchemaSnapshot, no database is needed. Like CompareSchemas it only reports
+// the types of columns, a column that only changes its nullability is not
+// listed.
+func DiffSnapshots(a, b []byte) (SchemaDiff, error) {
+	var as, bs snapshot
+	if err := json.Unmarshal(a, &as); err != nil {
+		return SchemaDiff{}, fmt.Errorf("ql: invalid schema snapshot: %v", err)
+	}
+	if err := json.Unmarshal(b, &bs); err != nil {
+		return SchemaDiff{}, fmt.Errorf("ql: invalid schema snapshot: %v", err)
+	}
+	return diffSnapshots(as, bs), nil
+}
+
+func (q *QL) snapshot() (snapshot, error) {
+	var s snapshot
+	names, err := q.ListTables()
+	if err != nil {
+		return s, err
+	}
+	notNull, err := q.notNullColumns()
+	if err != nil {
+		return s, err
+	}
+	for _, name := range names {
+		t := snapshotTable{Name: name, Columns: []snapshotColumn{}, Indexes: []snapshotIndex{}}
+		cols, err := q.ListColumns(name)
+		if err != nil {
+			return s, err
+		}
+		for _, c := range cols {
+			t.Columns = append(t.Columns, snapshotColumn{
+				Name:    c.Name,
+				Type:    canonicalType(c.Type),
+				NotNull: notNull[name][c.Name],
+			})
+		}
+		idx, err := groupIndexes(q, name)
+		if err != nil {
+			return s, err
+		}
+		for _, i := range idx {
+			t.Indexes = append(t.Indexes, snapshotIndex{Name: i.Index, Columns: i.Columns, Unique: i.Unique})
+		}
+		s.Tables = append(s.Tables, t)
+	}
+	if s.Tables == nil {
+		s.Tables = []snapshotTable{}
+	}
+	return s, nil
+}
+
+// notNullColumns returns the NOT NULL columns of every table. ql only creates
+// the __Column2 table holding them once a column has a constraint.
+func (q *QL) notNullColumns() (map[string]map[string]bool, error) {
+	o := make(map[string]map[string]bool)
+	if !q.HasTable("__Column2") {
+		return o, nil
+	}
+	rows, err := q.conn().Query("SELECT TableName, Name FROM __Column2 WHERE NotNull")
+	if err != nil {
+		return nil, err
+	}
+	defer func() {
+		_ = rows.Close()
+	}()
+	for rows.Next() {
+		var table, column string
+		if err = rows.Scan(&table, &column); err != nil {
+			return nil, err
+		}
+		if o[table] == nil {
+			o[table] = make(map[string]bool)
+		}
+		o[table][column] = true
+	}
+	return o, rows.Err()
+}
+
+func diffSnapshots(a, b snapshot) SchemaDiff {
+	var d SchemaDiff
+	inB := make(map[string]snapshotTable)
+	for _, t := range b.Tables {
+		inB[t.Name] = t
+	}
+	inA := make(map[string]bool)
+	for _, t := range a.Tables {
+		inA[t.Name] = true
+		bt, ok := inB[t.Name]
+		if !ok {
+			d.RemovedTables = append(d.RemovedTables, t.Name)
+			continue
+		}
+		compareTable(&d, t, bt)
+	}
+	for _, t := range b.Tables {
+		if !inA[t.Name] {
+			d.AddedTables = append(d.AddedTables, t.Name)
+		}
+	}
+	return d
+}
+
+func compareTable(d *SchemaDiff, a, b snapshotTable) {
+	types := make(map[string]string)
+	for _, c := range b.Columns {
+		types[c.Name] = c.Type
+	}
+	for _, c := range a.Columns {
+		to, ok := types[c.Name]
+		if !ok || to != c.Type {
+			d.Columns = append(d.Columns, ColumnDiff{Table: a.Name, Column: c.Name, From: c.Type, To: to})
+		}
+		delete(types, c.Name)
+	}
+	for _, c := range b.Columns {
+		if to, ok := types[c.Name]; ok {
+			d.Columns = append(d.Columns, ColumnDiff{Table: a.Name, Column: c.Name, To: to})
+		}
+	}
+
+	if reordered(a.Columns, b.Columns) {
+		d.Reordered = append(d.Reordered, a.Name)
+	}
+
+	ai, bi := a.indexDiffs(), b.indexDiffs()
+	for _, i := range ai {
+		if !containsIndex(bi, i) {
+			d.Indexes = append(d.Indexes, i)
+		}
+	}
+	for _, i := range bi {
+		if !containsIndex(ai, i) {
+			i.Added = true
+			d.Indexes = append(d.Indexes, i)
+		}
+	}
+}
+
+// reordered returns true if ALTER TABLE can not turn the columns of a into the
+// ones of b in the order of b, dropping and appending columns as needed.
+func reordered(a, b []snapshotColumn) bool {
+	inA := make(map[string]int)
+	for i, c := range a {
+		inA[c.Name] = i
+	}
+	last, added := -1, false
+	for _, c := range b {
+		i, ok := inA[c.Name]
+		if !ok {
+			added = true
+			continue
+		}
+		if added || i < last {
+			return true
+		}
+		last = i
+	}
+	return false
+}
+
+func (t snapshotTable) indexDiffs() []IndexDiff {
+	var o []IndexDiff
+	for _, i := range t.Indexes {
+		o = append(o, IndexDiff{Table: t.Name, Index: i.Name, Columns: i.Columns, Unique: i.Unique})
+	}
+	return o
+}
+
+// groupIndexes returns the indexes of tableName with their columns collected,
+// ordered by name.
+func groupIndexes(q *QL, tableName string) ([]IndexDiff, error) {
+	cols, err := q.ListIndexes(tableName)
+	if err != nil {
+		return nil, err
+	}
+	var o []IndexDiff
+	for _, c := range cols {
+		if len(o) == 0 || o[len(o)-1].Index != c.Index {
+			o = append(o, IndexDiff{Table: tableName, Index: c.Index, Unique: c.Unique})
+		}
+		last := &o[len(o)-1]
+		last.Columns = append(last.Columns, c.Column)
+	}
+	sort.Slice(o, func(i, j int) bool {
+		return o[i].Index < o[j].Index
+	})
+	return o, nil
+}
+
+func containsIndex(idx []IndexDiff, i IndexDiff) bool {
+	for _, v := range idx {
+		if v.Index == i.Index && v.Unique == i.Unique &&
+			strings.Join(v.Columns, ",") == strings.Join(i.Columns, ",") {
+			return true
+		}
+	}
+	return false
+}
+
+// RequiresRebuild returns true if the changes of from and to can not all be
+// applied with ALTER TABLE, CREATE and DROP statements, so the affected tables
+// have to be rebuilt by copying their records into a new table. The changes of
+// both diffs are considered, pass an empty SchemaDiff as to when checking a
+// single diff.
+//
+// ql can add and drop columns in place but has no way of changing the type of
+// a column, not even a widening one, or of moving a column: ALTER TABLE always
+// appends, so a table listed in Reordered has to be rebuilt too.
+func RequiresRebuild(from, to SchemaDiff) bool {
+	for _, d := range []SchemaDiff{from, to} {
+		if len(d.Reordered) > 0 {
+			return true
+		}
+		for _, c := range d.Columns {
+			if c.From != "" && c.To != "" && canonicalType(c.From) != canonicalType(c.To) {
+				return true
+			}
+		}
+	}
+	return false
+}
+
+// MigrationIsLossy returns true if applying the changes of diff to tableName
+// would lose data held by the database of q, together with the names of the
+// affected columns. A column loses data when it is removed while some record
+// has a value for it, or when its type changes and some of its values can not
+// be converted, see ValidateColumnValues. Removing the whole table loses the
+// columns that have values.
+func (q *QL) MigrationIsLossy(tableName string, diff SchemaDiff) (bool, []string, error) {
+	if q.db == nil {
+		return false, nil, ErrNoDB
+	}
+	var changes []ColumnDiff
+	for _, t := range diff.RemovedTables {
+		if t != tableName {
+			continue
+		}
+		cols, err := q.ListColumns(tableName)
+		if err != nil {
+			return false, nil, err
+		}
+		for _, c := range cols {
+			changes = append(changes, ColumnDiff{Table: tableName, Column: c.Name, From: c.Type})
+		}
+	}
+	for _, c := range diff.Columns {
+		if c.Table == tableName && c.From != "" {
+			changes = append(changes, c)
+		}
+	}
+	var affected []string
+	for _, c := range changes {
+		var lossy bool
+		if c.To == "" {
+			n, err := scalarInt64(q.conn(), fmt.Sprintf("SELECT count() FROM %s WHERE %s IS NOT NULL", tableName, c.Column))
+			if err != nil {
+				return false, nil, err
+			}
+			lossy = n > 0
+		} else {
+			invalid, err := q.ValidateColumnValues(tableName, c.Column, c.To)
+			if err != nil {
+				return false, nil, err
+			}
+			lossy = len(invalid) > 0
+		}
+		if lossy {
+			affected = append(affected, c.Column)
+		}
+	}
+	return len(affected) > 0, affected, nil
+}
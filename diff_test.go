@@ -0,0 +1,209 @@
+package ql
+
+import (
+	"database/sql"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+func TestCompareSchemas(t *testing.T) {
+	a := openMemory(t)
+	execTx(t, a.db, migration)
+
+	t.Run("matching", func(t *testing.T) {
+		b := openMemory(t)
+		execTx(t, b.db, migration)
+		d, err := CompareSchemas(a, b)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if !d.Empty() {
+			t.Errorf("expected no differences got %+v", d)
+		}
+	})
+	t.Run("extra column", func(t *testing.T) {
+		b := openMemory(t)
+		execTx(t, b.db, migration)
+		execTx(t, b.db, "ALTER TABLE Items ADD Price float64")
+		d, err := CompareSchemas(a, b)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if d.Empty() {
+			t.Fatal("expected differences")
+		}
+		exp := []ColumnDiff{{Table: "Items", Column: "Price", To: "float64"}}
+		if !reflect.DeepEqual(d.Columns, exp) {
+			t.Errorf("expected %v got %v", exp, d.Columns)
+		}
+	})
+	t.Run("tables and indexes", func(t *testing.T) {
+		b := openMemory(t)
+		execTx(t, b.db, `
+BEGIN TRANSACTION;
+	CREATE TABLE Orders (CustomerID int, Date time);
+	CREATE INDEX OrdersID ON Orders (id());
+	CREATE UNIQUE INDEX OrdersDate ON Orders (Date);
+	CREATE TABLE Customers (Name string);
+COMMIT;
+`)
+		d, err := CompareSchemas(a, b)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if !reflect.DeepEqual(d.AddedTables, []string{"Customers"}) {
+			t.Errorf("expected Customers to be added got %v", d.AddedTables)
+		}
+		if !reflect.DeepEqual(d.RemovedTables, []string{"Items"}) {
+			t.Errorf("expected Items to be removed got %v", d.RemovedTables)
+		}
+		exp := []IndexDiff{
+			{Table: "Orders", Index: "OrdersDate", Columns: []string{"Date"}},
+			{Table: "Orders", Index: "OrdersDate", Columns: []string{"Date"}, Unique: true, Added: true},
+		}
+		if !reflect.DeepEqual(d.Indexes, exp) {
+			t.Errorf("expected %v got %v", exp, d.Indexes)
+		}
+	})
+	if _, err := CompareSchemas(a, Memory()); err != ErrNoDB {
+		t.Errorf("expected %v got %v", ErrNoDB, err)
+	}
+}
+
+func TestRequiresRebuild(t *testing.T) {
+	a := openMemory(t)
+	execTx(t, a.db, migration)
+
+	db, err := sql.Open("ql-mem", t.Name()+"_added.db")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer func() {
+		_ = db.Close()
+	}()
+	added := Memory()
+	added.SetDB(db)
+	execTx(t, db, migration)
+	execTx(t, db, "ALTER TABLE Items ADD Price float64")
+	d, err := CompareSchemas(a, added)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if RequiresRebuild(d, SchemaDiff{}) {
+		t.Error("expected an added column to be applied in place")
+	}
+
+	narrowed := SchemaDiff{Columns: []ColumnDiff{{Table: "Items", Column: "Qty", From: "int64", To: "int8"}}}
+	if !RequiresRebuild(d, narrowed) {
+		t.Error("expected a narrowed column to require a rebuild")
+	}
+	if !RequiresRebuild(narrowed, SchemaDiff{}) {
+		t.Error("expected a narrowed column to require a rebuild")
+	}
+	same := SchemaDiff{Columns: []ColumnDiff{{Table: "Items", Column: "Qty", From: "int", To: "int64"}}}
+	if RequiresRebuild(same, SchemaDiff{}) {
+		t.Error("expected an alias to be the same type")
+	}
+
+	for _, v := range []struct {
+		name    string
+		schema  string
+		rebuild bool
+	}{
+		{"dropped column", "CREATE TABLE Items (OrderID int, Qty int)", false},
+		{"appended column", "CREATE TABLE Items (OrderID int, ProductID int, Qty int, Price float64)", false},
+		{"swapped columns", "CREATE TABLE Items (ProductID int, OrderID int, Qty int)", true},
+		{"inserted column", "CREATE TABLE Items (OrderID int, Price float64, ProductID int, Qty int)", true},
+	} {
+		t.Run(v.name, func(t *testing.T) {
+			b := openMemory(t)
+			execTx(t, b.db, v.schema)
+			d, err := CompareSchemas(a, b)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if rebuild := RequiresRebuild(d, SchemaDiff{}); rebuild != v.rebuild {
+				t.Errorf("expected %v got %v for %+v", v.rebuild, rebuild, d)
+			}
+			if reordered := len(d.Reordered) > 0; reordered != v.rebuild {
+				t.Errorf("expected Items to be reordered %v got %v", v.rebuild, d.Reordered)
+			}
+		})
+	}
+}
+
+func TestQL_MigrationIsLossy(t *testing.T) {
+	q := openMemory(t)
+	execTx(t, q.db, migration)
+	execTx(t, q.db, "INSERT INTO Items (OrderID, Qty) VALUES (1, 5), (2, 300)")
+	sample := []struct {
+		name     string
+		diff     SchemaDiff
+		lossy    bool
+		affected []string
+	}{
+		{"add", SchemaDiff{Columns: []ColumnDiff{{Table: "Items", Column: "Price", To: "float64"}}}, false, nil},
+		{"drop populated", SchemaDiff{Columns: []ColumnDiff{{Table: "Items", Column: "Qty", From: "int64"}}}, true, []string{"Qty"}},
+		{"drop empty", SchemaDiff{Columns: []ColumnDiff{{Table: "Items", Column: "ProductID", From: "int64"}}}, false, nil},
+		{"narrow", SchemaDiff{Columns: []ColumnDiff{{Table: "Items", Column: "Qty", From: "int64", To: "int8"}}}, true, []string{"Qty"}},
+		{"narrow in range", SchemaDiff{Columns: []ColumnDiff{{Table: "Items", Column: "OrderID", From: "int64", To: "int8"}}}, false, nil},
+		{"other table", SchemaDiff{Columns: []ColumnDiff{{Table: "Orders", Column: "CustomerID", From: "int64"}}}, false, nil},
+		{"drop table", SchemaDiff{RemovedTables: []string{"Items"}}, true, []string{"OrderID", "Qty"}},
+	}
+	for _, v := range sample {
+		lossy, affected, err := q.MigrationIsLossy("Items", v.diff)
+		if err != nil {
+			t.Fatalf("%s: %v", v.name, err)
+		}
+		if lossy != v.lossy || !reflect.DeepEqual(affected, v.affected) {
+			t.Errorf("%s: expected %v %v got %v %v", v.name, v.lossy, v.affected, lossy, affected)
+		}
+	}
+}
+
+func TestQL_SchemaSnapshot(t *testing.T) {
+	a := openMemory(t)
+	execTx(t, a.db, migration)
+	execTx(t, a.db, "CREATE TABLE Tags (Name string NOT NULL, Rank int8)")
+	s1, err := a.SchemaSnapshot()
+	if err != nil {
+		t.Fatal(err)
+	}
+	s2, err := a.SchemaSnapshot()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if string(s1) != string(s2) {
+		t.Errorf("expected the same snapshot got\n%s\n%s", s1, s2)
+	}
+	for _, v := range []string{`"name": "Tags"`, `"type": "int8"`, `"not_null": true`} {
+		if !strings.Contains(string(s1), v) {
+			t.Errorf("expected %s in\n%s", v, s1)
+		}
+	}
+
+	t.Run("diff", func(t *testing.T) {
+		b := openMemory(t)
+		execTx(t, b.db, migration)
+		execTx(t, b.db, "ALTER TABLE Items ADD Price float64")
+		s3, err := b.SchemaSnapshot()
+		if err != nil {
+			t.Fatal(err)
+		}
+		d, err := DiffSnapshots(s1, s3)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if !reflect.DeepEqual(d.RemovedTables, []string{"Tags"}) {
+			t.Errorf("expected Tags to be removed got %v", d.RemovedTables)
+		}
+		exp := []ColumnDiff{{Table: "Items", Column: "Price", To: "float64"}}
+		if !reflect.DeepEqual(d.Columns, exp) {
+			t.Errorf("expected %v got %v", exp, d.Columns)
+		}
+	})
+	if _, err := DiffSnapshots(s1, []byte("{")); err == nil {
+		t.Error("expected an error")
+	}
+}
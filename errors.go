@@ -0,0 +1,69 @@
+package ql
+
+import (
+	"database/sql"
+	"errors"
+	"regexp"
+	"strings"
+)
+
+// ErrorCategory is a coarse classification of the errors reported by ql, meant
+// for metrics and dashboards. The values are usable as labels.
+type ErrorCategory string
+
+// The categories returned by CategorizeError.
+const (
+	// CategoryConstraint is a write rejected by a unique index or a column
+	// constraint, like NOT NULL.
+	CategoryConstraint ErrorCategory = "constraint"
+
+	// CategoryNotFound is a missing table, column, index or record.
+	CategoryNotFound ErrorCategory = "not_found"
+
+	// CategorySyntax is a statement ql could not parse.
+	CategorySyntax ErrorCategory = "syntax"
+
+	// CategoryLock is a database held by another transaction or process, see
+	// SetRetryPolicy.
+	CategoryLock ErrorCategory = "lock"
+
+	// CategoryUnknown is any other error.
+	CategoryUnknown ErrorCategory = "unknown"
+)
+
+// syntaxErrorRe matches the line:column position ql puts in front of parse
+// errors, like 1:6: unexpected identifier.
+var syntaxErrorRe = regexp.MustCompile(`^\d+:\d+: `)
+
+// CategorizeError returns the category of err, found by inspecting the error
+// text since the ql driver has no typed errors. Errors of the dialect itself,
+// like ErrNoValue or a *DuplicateValuesError, are categorized too. A nil err
+// is CategoryUnknown.
+func CategorizeError(err error) ErrorCategory {
+	if err == nil {
+		return CategoryUnknown
+	}
+	var dup *DuplicateValuesError
+	switch {
+	case errors.Is(err, sql.ErrNoRows), errors.Is(err, ErrNoValue):
+		return CategoryNotFound
+	case errors.As(err, &dup):
+		return CategoryConstraint
+	}
+	msg := strings.TrimPrefix(err.Error(), "ql: ")
+	switch {
+	case syntaxErrorRe.MatchString(msg):
+		return CategorySyntax
+	case strings.Contains(msg, "constraint violation"),
+		strings.Contains(msg, "unique index"),
+		strings.Contains(msg, "duplicate value"):
+		return CategoryConstraint
+	case strings.Contains(msg, "does not exist"),
+		strings.Contains(msg, "unknown column"),
+		strings.Contains(msg, "unknown field"):
+		return CategoryNotFound
+	case isLockError(err):
+		return CategoryLock
+	}
+	return CategoryUnknown
+}
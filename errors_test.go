@@ -0,0 +1,56 @@
+package ql
+
+import (
+	"database/sql"
+	"errors"
+	"fmt"
+	"testing"
+)
+
+func TestCategorizeError(t *testing.T) {
+	sample := []struct {
+		err error
+		exp ErrorCategory
+	}{
+		{errors.New("cannot insert into unique index: duplicate value(s): [1]"), CategoryConstraint},
+		{errors.New("column b: constraint violation: NOT NULL"), CategoryConstraint},
+		{errors.New("column c: constraint violation: c > 0"), CategoryConstraint},
+		{&DuplicateValuesError{Table: "Users", Column: "Email"}, CategoryConstraint},
+		{errors.New("INSERT INTO nope: table does not exist"), CategoryNotFound},
+		{errors.New("DROP INDEX: index nope does not exist"), CategoryNotFound},
+		{errors.New("INSERT INTO t: unknown column zz"), CategoryNotFound},
+		{errors.New("unknown field zz"), CategoryNotFound},
+		{errors.New("table Blocks does not exist"), CategoryNotFound},
+		{errors.New("DROP INDEX: index ClockIdx does not exist"), CategoryNotFound},
+		{errors.New("INSERT INTO Locks: unknown column Blocked"), CategoryNotFound},
+		{errors.New("column Lock: constraint violation: NOT NULL"), CategoryConstraint},
+		{errors.New("1:8: unexpected identifier Locked"), CategorySyntax},
+		{sql.ErrNoRows, CategoryNotFound},
+		{ErrNoValue, CategoryNotFound},
+		{errors.New("1:6: unexpected identifier, expected Start or one of [$end, ';', ALTER]"), CategorySyntax},
+		{errors.New("ql: 1:12: unexpected FROM"), CategorySyntax},
+		{errors.New("database is locked"), CategoryLock},
+		{fmt.Errorf("open test.db: %v", "resource temporarily unavailable"), CategoryLock},
+		{errors.New("attempt to update the DB outside of a transaction"), CategoryUnknown},
+		{nil, CategoryUnknown},
+	}
+	for _, v := range sample {
+		if c := CategorizeError(v.err); c != v.exp {
+			t.Errorf("%v: expected %s got %s", v.err, v.exp, c)
+		}
+	}
+
+	q := openMemory(t)
+	execTx(t, q.db, "CREATE TABLE Users (Email string NOT NULL)")
+	err := q.transaction(func(tx queryer) error {
+		_, err := tx.Exec("INSERT INTO Users (Email) VALUES (NULL)")
+		return err
+	})
+	if c := CategorizeError(err); c != CategoryConstraint {
+		t.Errorf("%v: expected %s got %s", err, CategoryConstraint, c)
+	}
+	_, err = q.ScalarInt64("SELECT count() FROM Missing")
+	if c := CategorizeError(err); c != CategoryNotFound {
+		t.Errorf("%v: expected %s got %s", err, CategoryNotFound, c)
+	}
+}
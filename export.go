@@ -0,0 +1,336 @@
+package ql
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"math/big"
+	"sort"
+	"strings"
+	"time"
+)
+
+// IDColumn is the key that holds the id() of a record in the maps returned by
+// ExportRows.
+const IDColumn = "id()"
+
+// ExportRows returns all records of tableName in id() order, each as a map from
+// column name to value. The id() of the record is stored under IDColumn.
+//
+// Values are returned as ql stores them: blobs as []byte, time as time.Time,
+// duration as time.Duration, bigint as *big.Int and bigrat as *big.Rat, or as
+// a decimal string when set with SetExportRatPlaces. NULL is returned as nil.
+// Values of columns with a transformer, see RegisterTransformer, are the ones
+// returned by its OnLoad. Under SetBlobAsBase64 blobs are decoded, but the
+// string columns created for []byte fields in that mode can not be told apart
+// from other strings and hold the base64 text, which ScanBlob decodes.
+func (q *QL) ExportRows(tableName string) ([]map[string]interface{}, error) {
+	cols, err := q.ListColumns(tableName)
+	if err != nil {
+		return nil, err
+	}
+	if len(cols) == 0 {
+		return nil, fmt.Errorf("ql: table %s does not exist", tableName)
+	}
+	var result []map[string]interface{}
+	err = q.eachRow(tableName, cols, func(id int64, values []interface{}) error {
+		row := map[string]interface{}{IDColumn: id}
+		for i, c := range cols {
+			v, err := q.exportValue(tableName, c, values[i])
+			if err != nil {
+				return err
+			}
+			row[c.Name] = v
+		}
+		result = append(result, row)
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+	return result, nil
+}
+
+// SetExportRatPlaces makes ExportRows and ExportCSV write bigrat values as
+// decimal strings rounded to places decimal places, see RatToDecimalString,
+// instead of fractions like 1/3 which few tools understand. A negative places
+// restores the fractions. ImportCSV reads both forms.
+func (q *QL) SetExportRatPlaces(places int) {
+	q.ratAsDecimal = places >= 0
+	q.ratPlaces = places
+}
+
+// RatToDecimalString returns r as a decimal number rounded to places decimal
+// places, half away from zero, with trailing zeros kept: 1/3 at 2 places is
+// 0.33 and 1/4 at 4 places 0.2500. A nil r is an empty string.
+func RatToDecimalString(r *big.Rat, places int) string {
+	if r == nil {
+		return ""
+	}
+	if places < 0 {
+		places = 0
+	}
+	return r.FloatString(places)
+}
+
+// exportValue returns v, as decoded by decodeValue from the column c of
+// tableName, the way the export helpers hand it out: blobs decoded like
+// ScanBlob does, passed through the transformer of the column and with bigrat
+// values formatted as set with SetExportRatPlaces.
+func (q *QL) exportValue(tableName string, c Column, v interface{}) (interface{}, error) {
+	if b, ok := v.([]byte); ok && q.blobAsBase64 {
+		if err := q.ScanBlob(b, &b); err != nil {
+			return nil, fmt.Errorf("ql: column %s: %v", c.Name, err)
+		}
+		v = b
+	}
+	v, err := q.ScanValue(tableName, c.Name, v)
+	if err != nil {
+		return nil, fmt.Errorf("ql: column %s: %v", c.Name, err)
+	}
+	if r, ok := v.(*big.Rat); ok && q.ratAsDecimal {
+		return RatToDecimalString(r, q.ratPlaces), nil
+	}
+	return v, nil
+}
+
+// TableChecksum returns a SHA-256 hash of the values of all records of
+// tableName, hex encoded, to check that a copy of a table holds the same data.
+// Records are read in id() order but id() itself is not hashed, and columns
+// are hashed in name order, so tables with the same records have the same
+// checksum whatever their ids and column order.
+func (q *QL) TableChecksum(tableName string) (string, error) {
+	cols, err := q.ListColumns(tableName)
+	if err != nil {
+		return "", err
+	}
+	if len(cols) == 0 {
+		return "", fmt.Errorf("ql: table %s does not exist", tableName)
+	}
+	sort.Slice(cols, func(i, j int) bool {
+		return cols[i].Name < cols[j].Name
+	})
+	h := sha256.New()
+	err = q.eachRow(tableName, cols, func(_ int64, values []interface{}) error {
+		for i, v := range values {
+			fmt.Fprintf(h, "%s\x00%T\x00%s\x00", cols[i].Name, v, formatCSV(v))
+		}
+		_, err := h.Write([]byte{'\n'})
+		return err
+	})
+	if err != nil {
+		return "", err
+	}
+	return hex.EncodeToString(h.Sum(nil)), nil
+}
+
+// eachRow calls fn with the id() and the values of cols for every record of
+// tableName, in id() order. The values are decoded like the ones returned by
+// ExportRows and are only valid until fn returns.
+func (q *QL) eachRow(tableName string, cols []Column, fn func(id int64, values []interface{}) error) error {
+	read := []string{"id()"}
+	for _, c := range cols {
+		read = append(read, readExpr(c))
+	}
+	rows, err := q.conn().Query(fmt.Sprintf("SELECT %s FROM %s ORDER BY id()", strings.Join(read, ", "), tableName))
+	if err != nil {
+		return err
+	}
+	defer func() {
+		_ = rows.Close()
+	}()
+	var id int64
+	v := make([]interface{}, len(cols))
+	ptr := []interface{}{&id}
+	for i := range v {
+		ptr = append(ptr, &v[i])
+	}
+	for rows.Next() {
+		if err = rows.Scan(ptr...); err != nil {
+			return err
+		}
+		for i, c := range cols {
+			if v[i], err = decodeValue(c.Type, v[i]); err != nil {
+				return fmt.Errorf("ql: column %s: %v", c.Name, err)
+			}
+		}
+		if err = fn(id, v); err != nil {
+			return err
+		}
+	}
+	return rows.Err()
+}
+
+// decodeValue turns v, as handed out by database/sql for a column of type typ,
+// into the Go value ql stores. database/sql returns text for strings, bigint
+// and bigrat values and plain integers for durations.
+func decodeValue(typ string, v interface{}) (interface{}, error) {
+	if v == nil {
+		return nil, nil
+	}
+	b, isBytes := v.([]byte)
+	switch canonicalType(typ) {
+	case "blob":
+		if isBytes {
+			return append([]byte(nil), b...), nil
+		}
+	case "string":
+		if isBytes {
+			return string(b), nil
+		}
+	case "bigint":
+		if isBytes {
+			i, ok := new(big.Int).SetString(string(b), 10)
+			if !ok {
+				return nil, fmt.Errorf("invalid bigint %q", b)
+			}
+			return i, nil
+		}
+	case "bigrat":
+		if isBytes {
+			r, ok := new(big.Rat).SetString(string(b))
+			if !ok {
+				return nil, fmt.Errorf("invalid bigrat %q", b)
+			}
+			return r, nil
+		}
+	case "duration":
+		if i, ok := v.(int64); ok {
+			return time.Duration(i), nil
+		}
+	case "float32":
+		if f, ok := v.(float64); ok {
+			return float32(f), nil
+		}
+	}
+	return v, nil
+}
+
+// encodeValue turns v into an argument database/sql accepts. The big number
+// types are passed as text and converted back by the conversion wrapped around
+// their placeholder, see bindExpr.
+func encodeValue(v interface{}) interface{} {
+	switch x := v.(type) {
+	case *big.Int:
+		if x == nil {
+			return nil
+		}
+		return x.String()
+	case big.Int:
+		return x.String()
+	case *big.Rat:
+		if x == nil {
+			return nil
+		}
+		return x.String()
+	case big.Rat:
+		return x.String()
+	case time.Duration:
+		return int64(x)
+	}
+	return v
+}
+
+// valueType returns the ql type v is converted back to after encodeValue turned
+// it into text or an integer, or an empty string if it is passed as is.
+func valueType(v interface{}) string {
+	switch v.(type) {
+	case *big.Int, big.Int:
+		return "bigint"
+	case *big.Rat, big.Rat:
+		return "bigrat"
+	case time.Duration:
+		return "duration"
+	}
+	return ""
+}
+
+// bindArg returns the argument for v written to column of tableName by the
+// insert and import helpers: v is passed through the transformer of the column,
+// a []byte goes through BindBlob and the result is encoded for database/sql.
+func (q *QL) bindArg(tableName, column string, v interface{}) (interface{}, error) {
+	v, err := q.BindValue(tableName, column, v)
+	if err != nil {
+		return nil, err
+	}
+	if b, ok := v.([]byte); ok {
+		if v, err = q.BindBlob(b); err != nil {
+			return nil, err
+		}
+	}
+	return encodeValue(v), nil
+}
+
+// bindExpr returns the placeholder for the i'th argument converted to typ. The
+// conversion lets plain Go values, like an int64 for an int8 column or the
+// text of a bigrat, be stored in columns of any type.
+func (q *QL) bindExpr(typ string, i int) string {
+	return canonicalType(typ) + "(" + q.BindVar(i) + ")"
+}
+
+// ImportRows inserts rows into tableName and returns the number of inserted
+// records. Each row maps column names to values, the IDColumn key, as found in
+// the output of ExportRows, is ignored since ql assigns id() itself.
+//
+// All rows are inserted in a single transaction, if any of them fails, for
+// instance because it names a column that does not exist, none are inserted.
+func (q *QL) ImportRows(tableName string, rows []map[string]interface{}) (int, error) {
+	if err := q.ensureTable(tableName); err != nil {
+		return 0, err
+	}
+	cols, err := q.ListColumns(tableName)
+	if err != nil {
+		return 0, err
+	}
+	if len(cols) == 0 {
+		return 0, fmt.Errorf("ql: table %s does not exist", tableName)
+	}
+	types := make(map[string]string)
+	for _, c := range cols {
+		types[c.Name] = c.Type
+	}
+	n := 0
+	err = q.transaction(func(tx queryer) error {
+		if err := q.checkRowLimit(tx, tableName, len(rows)); err != nil {
+			return err
+		}
+		for i, row := range rows {
+			var names []string
+			for k := range row {
+				if k == IDColumn {
+					continue
+				}
+				if _, ok := types[k]; !ok {
+					return fmt.Errorf("ql: row %d: unknown column %s in table %s", i, k, tableName)
+				}
+				names = append(names, k)
+			}
+			if len(names) == 0 {
+				return fmt.Errorf("ql: row %d: no columns", i)
+			}
+			sort.Strings(names)
+			vars := make([]string, len(names))
+			args := make([]interface{}, len(names))
+			for j, k := range names {
+				v, err := q.bindArg(tableName, k, row[k])
+				if err != nil {
+					return fmt.Errorf("ql: row %d, column %s: %v", i, k, err)
+				}
+				vars[j] = q.bindExpr(types[k], j+1)
+				args[j] = v
+				names[j] = q.Quote(k)
+			}
+			query := fmt.Sprintf("INSERT INTO %s (%s) VALUES (%s)",
+				q.Quote(tableName), strings.Join(names, ", "), strings.Join(vars, ", "))
+			if _, err := tx.Exec(query, args...); err != nil {
+				return fmt.Errorf("ql: row %d: %v", i, err)
+			}
+			n++
+		}
+		return nil
+	})
+	if err != nil {
+		return 0, err
+	}
+	return n, nil
+}
@@ -0,0 +1,170 @@
+package ql
+
+import (
+	"bytes"
+	"math/big"
+	"testing"
+	"time"
+)
+
+func TestQL_ExportRows(t *testing.T) {
+	q := openMemory(t)
+	execTx(t, q.db, "CREATE TABLE files (name string, data blob, created time, ratio bigrat, size int)")
+	now := time.Date(2017, 3, 1, 10, 30, 0, 0, time.UTC)
+	execTx(t, q.db, "INSERT INTO files VALUES ($1, $2, $3, bigrat($4), $5)", "a.txt", []byte("hello"), now, "1/3", int64(5))
+	execTx(t, q.db, "INSERT INTO files (name) VALUES ($1)", "b.txt")
+
+	rows, err := q.ExportRows("files")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(rows) != 2 {
+		t.Fatalf("expected 2 rows got %d", len(rows))
+	}
+	a := rows[0]
+	for _, k := range []string{IDColumn, "name", "data", "created", "ratio", "size"} {
+		if _, ok := a[k]; !ok {
+			t.Errorf("expected key %s", k)
+		}
+	}
+	if _, ok := a[IDColumn].(int64); !ok {
+		t.Errorf("expected int64 id got %T", a[IDColumn])
+	}
+	if a["name"] != "a.txt" {
+		t.Errorf("expected a.txt got %v", a["name"])
+	}
+	if b, ok := a["data"].([]byte); !ok || !bytes.Equal(b, []byte("hello")) {
+		t.Errorf("expected hello got %v", a["data"])
+	}
+	if c, ok := a["created"].(time.Time); !ok || !c.Equal(now) {
+		t.Errorf("expected %v got %v", now, a["created"])
+	}
+	if r, ok := a["ratio"].(*big.Rat); !ok || r.Cmp(big.NewRat(1, 3)) != 0 {
+		t.Errorf("expected 1/3 got %v", a["ratio"])
+	}
+	if a["size"] != int64(5) {
+		t.Errorf("expected 5 got %v", a["size"])
+	}
+	b := rows[1]
+	if b["name"] != "b.txt" {
+		t.Errorf("expected b.txt got %v", b["name"])
+	}
+	if b["data"] != nil || b["ratio"] != nil {
+		t.Errorf("expected NULL values got %v", b)
+	}
+
+	if _, err = q.ExportRows("missing"); err == nil {
+		t.Error("expected an error")
+	}
+}
+
+func TestQL_ImportRows(t *testing.T) {
+	q := openMemory(t)
+	execTx(t, q.db, "CREATE TABLE files (name string, ratio bigrat, size int8)")
+	rows := []map[string]interface{}{
+		{"name": "a.txt", "ratio": big.NewRat(1, 3), "size": 5},
+		{"name": "b.txt", "size": int64(7)},
+		{"name": "c.txt", IDColumn: int64(100)},
+	}
+	n, err := q.ImportRows("files", rows)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if n != 3 {
+		t.Errorf("expected 3 got %d", n)
+	}
+	o, err := q.ExportRows("files")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(o) != 3 {
+		t.Fatalf("expected 3 rows got %d", len(o))
+	}
+	for i, v := range []string{"a.txt", "b.txt", "c.txt"} {
+		if o[i]["name"] != v {
+			t.Errorf("expected %s got %v", v, o[i]["name"])
+		}
+	}
+	if r, ok := o[0]["ratio"].(*big.Rat); !ok || r.Cmp(big.NewRat(1, 3)) != 0 {
+		t.Errorf("expected 1/3 got %v", o[0]["ratio"])
+	}
+	if o[1]["size"] != int64(7) {
+		t.Errorf("expected 7 got %v", o[1]["size"])
+	}
+}
+
+func TestQL_ImportRowsRollback(t *testing.T) {
+	q := openMemory(t)
+	execTx(t, q.db, "CREATE TABLE files (name string)")
+	rows := []map[string]interface{}{
+		{"name": "a.txt"},
+		{"name": "b.txt", "owner": "root"},
+	}
+	n, err := q.ImportRows("files", rows)
+	if err == nil {
+		t.Fatal("expected an error")
+	}
+	if n != 0 {
+		t.Errorf("expected 0 got %d", n)
+	}
+	c, err := q.CountRows("files")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if c != 0 {
+		t.Errorf("expected 0 got %d", c)
+	}
+}
+
+func TestRatToDecimalString(t *testing.T) {
+	for _, v := range []struct {
+		r      *big.Rat
+		places int
+		exp    string
+	}{
+		{big.NewRat(1, 3), 2, "0.33"},
+		{big.NewRat(1, 3), 4, "0.3333"},
+		{big.NewRat(1, 4), 2, "0.25"},
+		{big.NewRat(2, 3), 2, "0.67"},
+		{big.NewRat(-5, 2), 0, "-3"},
+		{nil, 2, ""},
+	} {
+		if s := RatToDecimalString(v.r, v.places); s != v.exp {
+			t.Errorf("%v at %d places: expected %s got %s", v.r, v.places, v.exp, s)
+		}
+	}
+}
+
+func TestQL_TableChecksum(t *testing.T) {
+	q := openMemory(t)
+	execTx(t, q.db, `
+BEGIN TRANSACTION;
+	CREATE TABLE files (name string, size int64, created time);
+	CREATE TABLE copy (created time, name string, size int64);
+	CREATE TABLE other (name string, size int64, created time);
+	INSERT INTO files VALUES ("a.txt", 5, now()), ("b.txt", NULL, NULL), ("", 0, NULL);
+	INSERT INTO other VALUES ("unrelated", 1, NULL);
+	DELETE FROM other;
+	INSERT INTO copy SELECT created, name, size FROM files ORDER BY id();
+	INSERT INTO other SELECT * FROM files ORDER BY id();
+	UPDATE other size = 6 WHERE name == "a.txt";
+COMMIT;
+`)
+	sum := func(table string) string {
+		s, err := q.TableChecksum(table)
+		if err != nil {
+			t.Fatal(err)
+		}
+		return s
+	}
+	files := sum("files")
+	if c := sum("copy"); c != files {
+		t.Errorf("expected the copy to have the checksum %s got %s", files, c)
+	}
+	if o := sum("other"); o == files {
+		t.Error("expected a modified copy to have another checksum")
+	}
+	if _, err := q.TableChecksum("missing"); err == nil {
+		t.Error("expected an error")
+	}
+}
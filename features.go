@@ -0,0 +1,128 @@
+package ql
+
+import (
+	"database/sql"
+	"database/sql/driver"
+	"errors"
+	"fmt"
+	"os"
+	"strings"
+	"sync"
+	"syscall"
+)
+
+// Feature is an optional part of the ql language that can be detected with
+// ProbeFeature.
+type Feature string
+
+// The features known to ProbeFeature.
+const (
+	FeatureBetween            Feature = "BETWEEN"
+	FeatureDistinct           Feature = "DISTINCT"
+	FeatureNow                Feature = "now()"
+	FeatureNestedTransactions Feature = "nested transactions"
+	FeatureOuterJoin          Feature = "OUTER JOIN"
+	FeatureAngleNotEqual      Feature = "<>"
+)
+
+// featureProbes holds the statement run to detect each feature.
+var featureProbes = map[Feature]string{
+	FeatureBetween:            "SELECT 1 BETWEEN 0 AND 2",
+	FeatureDistinct:           "SELECT DISTINCT Name FROM __Table",
+	FeatureNow:                "SELECT now()",
+	FeatureNestedTransactions: "BEGIN TRANSACTION; COMMIT;",
+	FeatureOuterJoin:          "SELECT * FROM __Table LEFT OUTER JOIN __Column ON true",
+	FeatureAngleNotEqual:      "SELECT 1 <> 2",
+}
+
+// featureCache holds the results of ProbeFeature. It is shared by the copies
+// of the dialect ngorm makes, so it is guarded by a mutex.
+type featureCache struct {
+	mu        sync.Mutex
+	supported map[Feature]bool
+}
+
+func (c *featureCache) get(feature Feature) (ok, cached bool) {
+	if c == nil {
+		return false, false
+	}
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	ok, cached = c.supported[feature]
+	return ok, cached
+}
+
+func (c *featureCache) set(feature Feature, ok bool) {
+	if c == nil {
+		return
+	}
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if c.supported == nil {
+		c.supported = make(map[Feature]bool)
+	}
+	c.supported[feature] = ok
+}
+
+// ProbeFeature returns true if the ql version the program is built with
+// supports feature. The check runs a statement exercising the feature in a
+// transaction that is rolled back, so it has no effect on the database. The
+// result is cached until the next SetDB, later calls for the same feature do
+// not touch the database. ProbeFeature is safe for concurrent use.
+//
+// A statement ql rejects means the feature is not supported. When the probe
+// could not be run, because the transaction could not begin or the database
+// is locked for instance, the error is returned and nothing is cached.
+func (q *QL) ProbeFeature(feature Feature) (bool, error) {
+	if ok, cached := q.features.get(feature); cached {
+		return ok, nil
+	}
+	query, ok := featureProbes[feature]
+	if !ok {
+		return false, fmt.Errorf("ql: unknown feature %q", feature)
+	}
+	if q.db == nil {
+		return false, ErrNoDB
+	}
+	tx, err := q.db.Begin()
+	if err != nil {
+		return false, err
+	}
+	defer func() {
+		_ = tx.Rollback()
+	}()
+	db := q.observed(tx)
+	if strings.HasPrefix(query, "SELECT") {
+		var rows *sql.Rows
+		if rows, err = db.Query(query); err == nil {
+			for rows.Next() {
+			}
+			err = rows.Err()
+			_ = rows.Close()
+		}
+	} else {
+		_, err = db.Exec(query)
+	}
+	if err != nil && !isRejection(err) {
+		return false, err
+	}
+	q.features.set(feature, err == nil)
+	return err == nil, nil
+}
+
+// isRejection returns true if err is ql refusing a statement, as opposed to
+// a failure to run it like a lock, a closed connection or an I/O error.
+func isRejection(err error) bool {
+	var pathErr *os.PathError
+	var errno syscall.Errno
+	switch {
+	case isLockError(err),
+		errors.Is(err, driver.ErrBadConn),
+		errors.Is(err, sql.ErrConnDone),
+		errors.Is(err, sql.ErrTxDone),
+		errors.As(err, &pathErr),
+		errors.As(err, &errno):
+		return false
+	}
+	return true
+}
@@ -0,0 +1,87 @@
+package ql
+
+import (
+	"database/sql"
+	"errors"
+	"sync"
+	"testing"
+)
+
+func TestQL_ProbeFeature(t *testing.T) {
+	q := openMemory(t)
+	r := &Recorder{}
+	q.SetStatementRecorder(r)
+	for _, v := range []struct {
+		feature Feature
+		ok      bool
+	}{
+		{FeatureBetween, true},
+		{FeatureNow, true},
+		{FeatureNestedTransactions, true},
+		{FeatureAngleNotEqual, false},
+	} {
+		for i := 0; i < 2; i++ {
+			ok, err := q.ProbeFeature(v.feature)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if ok != v.ok {
+				t.Errorf("%s: expected %v got %v", v.feature, v.ok, ok)
+			}
+			n := len(r.Reset())
+			if i == 0 && n != 1 {
+				t.Errorf("%s: expected the probe to run got %d statements", v.feature, n)
+			}
+			if i == 1 && n != 0 {
+				t.Errorf("%s: expected a cached result got %d statements", v.feature, n)
+			}
+		}
+	}
+	if _, err := q.ProbeFeature("WINDOW"); err == nil {
+		t.Error("expected an error for an unknown feature")
+	}
+	if _, err := Memory().ProbeFeature(FeatureDistinct); err != ErrNoDB {
+		t.Errorf("expected %v got %v", ErrNoDB, err)
+	}
+
+	// SetDB clears the cache
+	q.SetDB(q.db)
+	r.Reset()
+	if _, err := q.ProbeFeature(FeatureBetween); err != nil {
+		t.Fatal(err)
+	}
+	if n := len(r.Reset()); n != 1 {
+		t.Errorf("expected the probe to run again after SetDB got %d statements", n)
+	}
+}
+
+func TestQL_ProbeFeature_transient(t *testing.T) {
+	q := openMemory(t)
+	db := &lockedDB{DB: q.db.(*sql.DB), failures: 1, err: errors.New("database is locked")}
+	q.SetDB(db)
+	if _, err := q.ProbeFeature(FeatureDistinct); err != db.err {
+		t.Fatalf("expected %v got %v", db.err, err)
+	}
+	ok, err := q.ProbeFeature(FeatureDistinct)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !ok {
+		t.Error("expected a failed probe not to be cached")
+	}
+}
+
+func TestQL_ProbeFeature_concurrent(t *testing.T) {
+	q := openMemory(t)
+	var wg sync.WaitGroup
+	for i := 0; i < 8; i++ {
+		wg.Add(1)
+		go func(f Feature) {
+			defer wg.Done()
+			if _, err := q.ProbeFeature(f); err != nil {
+				t.Error(err)
+			}
+		}([]Feature{FeatureBetween, FeatureNow, FeatureOuterJoin, FeatureAngleNotEqual}[i%4])
+	}
+	wg.Wait()
+}
@@ -0,0 +1,543 @@
+package ql
+
+import (
+	"database/sql"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"sort"
+	"strings"
+)
+
+var (
+	// ErrMemoryDatabase is returned by operations that only make sense for a
+	// file backed database when they are invoked on a memory dialect.
+	ErrMemoryDatabase = errors.New("ql: operation is not supported by the memory database")
+
+	// ErrNoPath is returned when a file backed operation needs the path of
+	// the database file but the dialect was not created with FileWithPath.
+	ErrNoPath = errors.New("ql: file database has no path, create the dialect with FileWithPath(path) and open the same path")
+
+	// ErrNoDB is returned when an operation needs the database handle before
+	// SetDB was called.
+	ErrNoDB = errors.New("ql: database handle is not set, call SetDB first")
+)
+
+// FileWithPath returns the dialect for the file backed ql database stored at
+// path. It behaves exactly like File, but the dialect knows where the database
+// lives on disk which is required by the maintenance methods like Compact.
+//
+// The path should be the same one passed to sql.Open.
+func FileWithPath(path string) *QL {
+	return &QL{name: "ql", path: path}
+}
+
+// isMemory returns true if q is the in memory dialect.
+func (q *QL) isMemory() bool {
+	return q.name == "ql-mem"
+}
+
+// filePath returns the path of the database file, or an error if q is not
+// backed by a known file.
+func (q *QL) filePath() (string, error) {
+	if q.isMemory() {
+		return "", ErrMemoryDatabase
+	}
+	if q.path == "" {
+		return "", ErrNoPath
+	}
+	return q.path, nil
+}
+
+// Ping verifies that the dialect is ready to be used.
+//
+// The file dialect returned by File does not know which file it works with, so
+// a forgotten file name only shows up as an opaque driver error. Ping reports
+// ErrNoPath for such a dialect and otherwise pings the database handle. The
+// error of the hook set with SetOnConnect, if any, is reported as well.
+func (q *QL) Ping() error {
+	if _, err := q.filePath(); err == ErrNoPath {
+		return err
+	}
+	if q.db == nil {
+		return ErrNoDB
+	}
+	if q.connectErr != nil {
+		return q.connectErr
+	}
+	if p, ok := q.db.(interface {
+		Ping() error
+	}); ok {
+		return p.Ping()
+	}
+	return nil
+}
+
+// DatabaseSize returns the size in bytes of the file backing the database.
+func (q *QL) DatabaseSize() (int64, error) {
+	path, err := q.filePath()
+	if err != nil {
+		return 0, err
+	}
+	st, err := os.Stat(path)
+	if err != nil {
+		return 0, err
+	}
+	return st.Size(), nil
+}
+
+// Compact reclaims the space left behind by deleted records in a file backed
+// database.
+//
+// ql has no VACUUM like statement and its file never shrinks, so Compact
+// rewrites the database into a fresh file next to the original, copying all
+// tables, records and indexes, and then swaps it in place of the original.
+// Because the file is replaced the current handle is closed and the dialect is
+// given a new one, so after Compact returns use the handle set on the dialect.
+// Compact must not be called while other handles to the same file are open.
+//
+// Every record keeps its id(), so columns that store the id() of other records
+// stay valid. ql hands out id() values from a single counter, Compact advances
+// it over the values of deleted records by inserting and deleting throwaway
+// records. If an id() can not be kept Compact fails and leaves the original
+// file untouched.
+func (q *QL) Compact() error {
+	path, err := q.filePath()
+	if err != nil {
+		return err
+	}
+	if q.db == nil {
+		return ErrNoDB
+	}
+	tmp := path + ".compact"
+	if _, err = os.Stat(tmp); err == nil {
+		return fmt.Errorf("ql: compact: temporary file %s already exists", tmp)
+	}
+	dst, err := sql.Open("ql", tmp)
+	if err != nil {
+		return err
+	}
+	err = compactDatabase(q.conn(), dst)
+	if cerr := dst.Close(); err == nil {
+		err = cerr
+	}
+	if err != nil {
+		_ = os.Remove(tmp)
+		return fmt.Errorf("ql: compact: %v", err)
+	}
+	if err = q.db.Close(); err != nil {
+		_ = os.Remove(tmp)
+		return err
+	}
+	if err = os.Rename(tmp, path); err != nil {
+		return err
+	}
+	db, err := sql.Open("ql", path)
+	if err != nil {
+		return err
+	}
+	q.SetDB(db)
+	return nil
+}
+
+// SetBackupOverwrite makes Backup replace an existing file at its destination
+// instead of failing.
+func (q *QL) SetBackupOverwrite(ok bool) {
+	q.backupOverwrite = ok
+}
+
+// Backup writes a copy of a file backed database to destPath.
+//
+// The database file is copied byte for byte while a transaction is held on the
+// dialect's handle. ql serializes transactions, so no commit can land while
+// the copy is taken and the copy is an exact, consistent image of the
+// database, id() values included. Changes not yet committed by a transaction
+// set as the handle of the dialect are not part of the copy. The copy is
+// written next to destPath first and only moved in place once it is complete.
+// Unless SetBackupOverwrite is in effect an existing destPath is an error.
+func (q *QL) Backup(destPath string) error {
+	path, err := q.filePath()
+	if err != nil {
+		return err
+	}
+	if q.db == nil {
+		return ErrNoDB
+	}
+	if _, err = os.Stat(destPath); err == nil && !q.backupOverwrite {
+		return fmt.Errorf("ql: backup: %s already exists", destPath)
+	}
+	tmp := destPath + ".backup"
+	if _, err = os.Stat(tmp); err == nil {
+		return fmt.Errorf("ql: backup: temporary file %s already exists", tmp)
+	}
+	err = q.transaction(func(queryer) error {
+		return copyFile(path, tmp)
+	})
+	if err != nil {
+		_ = os.Remove(tmp)
+		return fmt.Errorf("ql: backup: %v", err)
+	}
+	return os.Rename(tmp, destPath)
+}
+
+// copyFile copies the contents of src to a new file dst and syncs it to disk.
+func copyFile(src, dst string) error {
+	in, err := os.Open(src)
+	if err != nil {
+		return err
+	}
+	defer func() {
+		_ = in.Close()
+	}()
+	out, err := os.OpenFile(dst, os.O_WRONLY|os.O_CREATE|os.O_EXCL, 0666)
+	if err != nil {
+		return err
+	}
+	if _, err = io.Copy(out, in); err == nil {
+		err = out.Sync()
+	}
+	if cerr := out.Close(); err == nil {
+		err = cerr
+	}
+	return err
+}
+
+// compactPadTable is the scratch table Compact fills with throwaway records to
+// move the id() counter of the new database over the id() values of deleted
+// records.
+const compactPadTable = "__ql_compact"
+
+// compactPadBatch is the number of throwaway records Compact inserts at once.
+const compactPadBatch = 512
+
+// compactDatabase recreates all user tables of src in dst together with their
+// records and indexes, keeping the id() of every record. Everything is done in
+// a single transaction on dst.
+func compactDatabase(src queryer, dst *sql.DB) error {
+	tx, err := dst.Begin()
+	if err != nil {
+		return err
+	}
+	c := &compactor{
+		src:     src,
+		tx:      tx,
+		schemas: make(map[string]string),
+		created: make(map[string]bool),
+	}
+	if err = c.run(); err != nil {
+		_ = tx.Rollback()
+		return err
+	}
+	return tx.Commit()
+}
+
+// compactEvent is a statement that consumed id() values in the original
+// database: the creation of an index or of a table with constrained or
+// defaulted columns, both of which store records in the meta data tables.
+// Compact runs it at the same position among the records.
+type compactEvent struct {
+	pos   int64
+	table string
+	index *index
+}
+
+// compactor copies a database while keeping the id() of every record.
+//
+// ql hands out id() values from a single counter shared by all tables and its
+// own meta data, and the only way to move it forward is to insert records. The
+// compactor replays the records of all tables in id() order and fills the gaps
+// left by deleted records with records of a scratch table it deletes again.
+type compactor struct {
+	src     queryer
+	tx      *sql.Tx
+	last    int64 // last id() used in the new database, -1 when unknown
+	schemas map[string]string
+	created map[string]bool
+}
+
+func (c *compactor) run() error {
+	names, err := tables(c.src)
+	if err != nil {
+		return err
+	}
+	events, err := compactEvents(c.src, names)
+	if err != nil {
+		return err
+	}
+	deferred := make(map[string]bool)
+	for _, e := range events {
+		if e.index == nil {
+			deferred[e.table] = true
+		}
+	}
+	for _, name := range names {
+		if c.schemas[name], err = tableSchema(c.src, name); err != nil {
+			return err
+		}
+		if !deferred[name] {
+			if err = c.create(name); err != nil {
+				return err
+			}
+		}
+	}
+	if _, err = c.tx.Exec(fmt.Sprintf("CREATE TABLE %s (x bool)", compactPadTable)); err != nil {
+		return err
+	}
+	// Creating tables without constraints uses no id() values.
+	c.last = 0
+	var cursors []*compactCursor
+	defer func() {
+		for _, r := range cursors {
+			_ = r.rows.Close()
+		}
+	}()
+	for _, name := range names {
+		r, err := openCompactCursor(c.src, name)
+		if err != nil {
+			return err
+		}
+		if r != nil {
+			cursors = append(cursors, r)
+		}
+	}
+	for {
+		var r *compactCursor
+		for _, v := range cursors {
+			if !v.done && (r == nil || v.id < r.id) {
+				r = v
+			}
+		}
+		for len(events) > 0 && (r == nil || events[0].pos < r.id) {
+			if err = c.runEvent(events[0]); err != nil {
+				return err
+			}
+			events = events[1:]
+		}
+		if r == nil {
+			break
+		}
+		if err = c.insert(r); err != nil {
+			return err
+		}
+		if err = r.next(); err != nil {
+			return err
+		}
+	}
+	_, err = c.tx.Exec(fmt.Sprintf("DROP TABLE %s", compactPadTable))
+	return err
+}
+
+// create creates tableName in the new database unless it already exists.
+func (c *compactor) create(tableName string) error {
+	if c.created[tableName] {
+		return nil
+	}
+	if _, err := c.tx.Exec(c.schemas[tableName]); err != nil {
+		return err
+	}
+	c.created[tableName] = true
+	c.last = -1
+	return nil
+}
+
+func (c *compactor) runEvent(e compactEvent) error {
+	if err := c.create(e.table); err != nil {
+		return err
+	}
+	if e.index != nil {
+		if _, err := c.tx.Exec(createIndexSQL(*e.index)); err != nil {
+			return err
+		}
+		c.last = -1
+	}
+	return nil
+}
+
+// insert copies the current record of r. When the counter is unknown, after a
+// statement that used meta data records, the record is inserted first to learn
+// it and moved if it landed before its id().
+func (c *compactor) insert(r *compactCursor) error {
+	if err := c.create(r.table); err != nil {
+		return err
+	}
+	if c.last >= 0 {
+		if err := c.pad(r.id - 1); err != nil {
+			return err
+		}
+	}
+	id, err := c.exec(r.insert, r.values...)
+	if err == nil && c.last < 0 && id < r.id {
+		_, err = c.tx.Exec(fmt.Sprintf("DELETE FROM %s WHERE id() == $1", r.table), id)
+		c.last = id
+		if err == nil {
+			err = c.pad(r.id - 1)
+		}
+		if err == nil {
+			id, err = c.exec(r.insert, r.values...)
+		}
+	}
+	if err != nil {
+		return err
+	}
+	if id != r.id {
+		return fmt.Errorf("cannot keep id() %d of a record of %s", r.id, r.table)
+	}
+	c.last = id
+	return nil
+}
+
+// pad inserts and deletes throwaway records until the last id() used is to.
+func (c *compactor) pad(to int64) error {
+	for c.last < to {
+		n := to - c.last
+		if n > compactPadBatch {
+			n = compactPadBatch
+		}
+		values := strings.TrimSuffix(strings.Repeat("(false), ", int(n)), ", ")
+		id, err := c.exec(fmt.Sprintf("INSERT INTO %s VALUES %s", compactPadTable, values))
+		if err != nil {
+			return err
+		}
+		if _, err = c.tx.Exec(fmt.Sprintf("DELETE FROM %s", compactPadTable)); err != nil {
+			return err
+		}
+		c.last = id
+	}
+	if c.last != to {
+		return fmt.Errorf("cannot keep id() %d, it is already used", to+1)
+	}
+	return nil
+}
+
+// exec runs query and returns the id() of the last record it inserted.
+func (c *compactor) exec(query string, args ...interface{}) (int64, error) {
+	res, err := c.tx.Exec(query, args...)
+	if err != nil {
+		return 0, err
+	}
+	return res.LastInsertId()
+}
+
+// compactEvents returns the statements of names that used id() values in db,
+// ordered by the first id() value they used.
+func compactEvents(db queryer, names []string) ([]compactEvent, error) {
+	var events []compactEvent
+	known := make(map[string]bool)
+	for _, name := range names {
+		known[name] = true
+	}
+	n, err := scalarInt64(db, "select count() from __Table where Name=$1", "__Column2")
+	if err != nil {
+		return nil, err
+	}
+	if n > 0 {
+		// min(id()) is always NULL in ql, the first record of every table wins.
+		rows, err := db.Query("select id(), TableName from __Column2 order by id()")
+		if err != nil {
+			return nil, err
+		}
+		seen := make(map[string]bool)
+		for rows.Next() {
+			var e compactEvent
+			if err = rows.Scan(&e.pos, &e.table); err != nil {
+				_ = rows.Close()
+				return nil, err
+			}
+			if known[e.table] && !seen[e.table] {
+				seen[e.table] = true
+				events = append(events, e)
+			}
+		}
+		_ = rows.Close()
+		if err = rows.Err(); err != nil {
+			return nil, err
+		}
+	}
+	for _, name := range names {
+		idx, err := indexes(db, name)
+		if err != nil {
+			return nil, err
+		}
+		for k := range idx {
+			pos, err := scalarInt64(db, "select id() from __Index2 where IndexName=$1", idx[k].Name)
+			if err != nil {
+				return nil, err
+			}
+			events = append(events, compactEvent{pos: pos, table: name, index: &idx[k]})
+		}
+	}
+	sort.Slice(events, func(i, j int) bool {
+		return events[i].pos < events[j].pos
+	})
+	return events, nil
+}
+
+// compactCursor walks the records of a table in id() order.
+//
+// Values are read through database/sql which hands bigint and bigrat values
+// back as text, so every value is passed through a conversion to the column
+// type when it is inserted.
+type compactCursor struct {
+	table  string
+	insert string
+	rows   *sql.Rows
+	done   bool
+	id     int64
+	values []interface{}
+}
+
+// openCompactCursor returns a cursor positioned at the first record of
+// tableName, or nil if the table has no columns.
+func openCompactCursor(db queryer, tableName string) (*compactCursor, error) {
+	cols, err := columns(db, tableName)
+	if err != nil || len(cols) == 0 {
+		return nil, err
+	}
+	var read, names, values []string
+	for i, c := range cols {
+		read = append(read, readExpr(c))
+		names = append(names, c.Name)
+		values = append(values, fmt.Sprintf("%s($%d)", c.Type, i+1))
+	}
+	rows, err := db.Query(fmt.Sprintf("SELECT id(), %s FROM %s ORDER BY id()",
+		strings.Join(read, ", "), tableName))
+	if err != nil {
+		return nil, err
+	}
+	r := &compactCursor{
+		table: tableName,
+		insert: fmt.Sprintf("INSERT INTO %s (%s) VALUES (%s)",
+			tableName, strings.Join(names, ", "), strings.Join(values, ", ")),
+		rows:   rows,
+		values: make([]interface{}, len(cols)),
+	}
+	if err = r.next(); err != nil {
+		_ = rows.Close()
+		return nil, err
+	}
+	return r, nil
+}
+
+// next moves r to the following record.
+func (r *compactCursor) next() error {
+	if !r.rows.Next() {
+		r.done = true
+		return r.rows.Err()
+	}
+	r.values = make([]interface{}, len(r.values))
+	ptr := []interface{}{&r.id}
+	for i := range r.values {
+		ptr = append(ptr, &r.values[i])
+	}
+	return r.rows.Scan(ptr...)
+}
+
+// readExpr returns the expression used to select c. The ql driver is unable to
+// hand float32 values to database/sql so they are widened on the way out.
+func readExpr(c Column) string {
+	if c.Type == "float32" {
+		return "float64(" + c.Name + ")"
+	}
+	return c.Name
+}
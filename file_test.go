@@ -0,0 +1,323 @@
+package ql
+
+import (
+	"database/sql"
+	"fmt"
+	"path/filepath"
+	"reflect"
+	"testing"
+
+	"github.com/akamajoris/ngorm/model"
+)
+
+func openFile(t *testing.T) (*QL, string) {
+	path := filepath.Join(t.TempDir(), "test.db")
+	db, err := sql.Open("ql", path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	q := FileWithPath(path)
+	q.SetDB(db)
+	return q, path
+}
+
+func TestQL_Compact(t *testing.T) {
+	q, _ := openFile(t)
+	defer func() {
+		_ = q.db.Close()
+	}()
+	execTx(t, q.db, migration)
+	tx, err := q.db.Begin()
+	if err != nil {
+		t.Fatal(err)
+	}
+	for i := 0; i < 500; i++ {
+		_, err = tx.Exec("INSERT INTO Items VALUES ($1, $2, $3)", int64(i), int64(i), int64(i%7))
+		if err != nil {
+			t.Fatal(err)
+		}
+	}
+	if err = tx.Commit(); err != nil {
+		t.Fatal(err)
+	}
+	execTx(t, q.db, "DELETE FROM Items WHERE OrderID >= 10")
+	if err = q.SetColumnComment("Items", "Qty", "ordered quantity"); err != nil {
+		t.Fatal(err)
+	}
+	seedReferences(t, q.db)
+	execTx(t, q.db, `
+	CREATE TABLE Notes (OrderID int NOT NULL, Text string DEFAULT "none");
+	INSERT INTO Notes (OrderID) SELECT id() FROM Orders;
+	CREATE UNIQUE INDEX NotesOrderID ON Notes (OrderID);
+	INSERT INTO Items SELECT id(), 13, 4 FROM Orders WHERE CustomerID == 3;
+	DELETE FROM Items WHERE OrderID == 0;
+	`)
+	orders, items := recordIDs(t, q.db, "Orders"), recordIDs(t, q.db, "Items")
+	notes := recordIDs(t, q.db, "Notes")
+	customers := itemCustomers(t, q.db)
+
+	if err = q.Compact(); err != nil {
+		t.Fatal(err)
+	}
+	checkReferences(t, q.db, orders, items, customers)
+	if v := recordIDs(t, q.db, "Notes"); !reflect.DeepEqual(v, notes) {
+		t.Errorf("expected Notes ids %v got %v", notes, v)
+	}
+	var text string
+	err = q.db.QueryRow("SELECT Text FROM Notes LIMIT 1").Scan(&text)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if text != "none" {
+		t.Errorf("expected none got %s", text)
+	}
+	if q.HasTable(compactPadTable) {
+		t.Errorf("expected %s to be dropped", compactPadTable)
+	}
+	for _, v := range []string{"Orders", "Items"} {
+		if !q.HasTable(v) {
+			t.Errorf("expected table %s to exist", v)
+		}
+	}
+	for _, v := range []struct{ table, index string }{
+		{"Orders", "OrdersID"},
+		{"Orders", "OrdersDate"},
+		{"Items", "ItemsOrderID"},
+		{"Notes", "NotesOrderID"},
+	} {
+		if !q.HasIndex(v.table, v.index) {
+			t.Errorf("expected index %s on %s", v.index, v.table)
+		}
+	}
+	var count int
+	err = q.db.QueryRow("SELECT count() FROM Items").Scan(&count)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if count != 12 {
+		t.Errorf("expected 12 got %d", count)
+	}
+	var qty int
+	err = q.db.QueryRow("SELECT Qty FROM Items WHERE OrderID == 9 && ProductID == 9").Scan(&qty)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if qty != 2 {
+		t.Errorf("expected 2 got %d", qty)
+	}
+	c, err := q.ColumnComment("Items", "Qty")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if c != "ordered quantity" {
+		t.Errorf("expected ordered quantity got %s", c)
+	}
+}
+
+func TestQL_CompactMemory(t *testing.T) {
+	q := Memory()
+	err := q.Compact()
+	if err != ErrMemoryDatabase {
+		t.Errorf("expected %v got %v", ErrMemoryDatabase, err)
+	}
+	q = File()
+	err = q.Compact()
+	if err != ErrNoPath {
+		t.Errorf("expected %v got %v", ErrNoPath, err)
+	}
+}
+
+func TestQL_Ping(t *testing.T) {
+	db, err := sql.Open("ql", "")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer func() {
+		_ = db.Close()
+	}()
+	q := File()
+	q.SetDB(db)
+	err = q.Ping()
+	if err != ErrNoPath {
+		t.Errorf("expected %v got %v", ErrNoPath, err)
+	}
+
+	q, _ = openFile(t)
+	defer func() {
+		_ = q.db.Close()
+	}()
+	if err = q.Ping(); err != nil {
+		t.Error(err)
+	}
+	if err = FileWithPath("test.db").Ping(); err != ErrNoDB {
+		t.Errorf("expected %v got %v", ErrNoDB, err)
+	}
+	if err = openMemory(t).Ping(); err != nil {
+		t.Error(err)
+	}
+}
+
+func TestQL_DatabaseSize(t *testing.T) {
+	q, _ := openFile(t)
+	defer func() {
+		_ = q.db.Close()
+	}()
+	execTx(t, q.db, migration)
+	size, err := q.DatabaseSize()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if size <= 0 {
+		t.Errorf("expected positive size got %d", size)
+	}
+	tx, err := q.db.Begin()
+	if err != nil {
+		t.Fatal(err)
+	}
+	for i := 0; i < 200; i++ {
+		_, err = tx.Exec("INSERT INTO Items VALUES ($1, $2, $3)", int64(i), int64(i), int64(i))
+		if err != nil {
+			t.Fatal(err)
+		}
+	}
+	if err = tx.Commit(); err != nil {
+		t.Fatal(err)
+	}
+	grown, err := q.DatabaseSize()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if grown <= size {
+		t.Errorf("expected size to grow from %d got %d", size, grown)
+	}
+
+	if _, err = Memory().DatabaseSize(); err != ErrMemoryDatabase {
+		t.Errorf("expected %v got %v", ErrMemoryDatabase, err)
+	}
+}
+
+// seedReferences fills Orders and Items so that Items.OrderID holds the id() of
+// an order, leaving gaps in the id() values by deleting some of the records.
+func seedReferences(t *testing.T, db model.SQLCommon) {
+	execTx(t, db, "INSERT INTO Orders (CustomerID) VALUES (1), (2), (3), (4)")
+	execTx(t, db, `
+	INSERT INTO Items SELECT id(), 10, 2 FROM Orders WHERE CustomerID == 1;
+	INSERT INTO Items SELECT id(), 11, 3 FROM Orders WHERE CustomerID == 3;
+	INSERT INTO Items SELECT id(), 12, 1 FROM Orders WHERE CustomerID == 4;
+	DELETE FROM Orders WHERE CustomerID == 2;
+	DELETE FROM Items WHERE ProductID == 10;
+	`)
+}
+
+// recordIDs returns the id() values of the records of tableName in order.
+func recordIDs(t *testing.T, db model.SQLCommon, tableName string) []int64 {
+	rows, err := db.Query(fmt.Sprintf("SELECT id() FROM %s ORDER BY id()", tableName))
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer func() {
+		_ = rows.Close()
+	}()
+	var ids []int64
+	for rows.Next() {
+		var id int64
+		if err = rows.Scan(&id); err != nil {
+			t.Fatal(err)
+		}
+		ids = append(ids, id)
+	}
+	if err = rows.Err(); err != nil {
+		t.Fatal(err)
+	}
+	return ids
+}
+
+// itemCustomers maps the ProductID of every item to the CustomerID of the
+// order its OrderID refers to.
+func itemCustomers(t *testing.T, db model.SQLCommon) map[int64]int64 {
+	rows, err := db.Query(`SELECT Items.ProductID, Orders.CustomerID FROM Items, Orders
+	WHERE Items.OrderID == id(Orders)`)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer func() {
+		_ = rows.Close()
+	}()
+	m := make(map[int64]int64)
+	for rows.Next() {
+		var product, customer int64
+		if err = rows.Scan(&product, &customer); err != nil {
+			t.Fatal(err)
+		}
+		m[product] = customer
+	}
+	if err = rows.Err(); err != nil {
+		t.Fatal(err)
+	}
+	return m
+}
+
+// checkReferences verifies that got holds the same id() values and references
+// as the ones recorded from the source database.
+func checkReferences(t *testing.T, got model.SQLCommon, orders, items []int64, customers map[int64]int64) {
+	t.Helper()
+	if v := recordIDs(t, got, "Orders"); !reflect.DeepEqual(v, orders) {
+		t.Errorf("expected Orders ids %v got %v", orders, v)
+	}
+	if v := recordIDs(t, got, "Items"); !reflect.DeepEqual(v, items) {
+		t.Errorf("expected Items ids %v got %v", items, v)
+	}
+	if v := itemCustomers(t, got); !reflect.DeepEqual(v, customers) {
+		t.Errorf("expected references %v got %v", customers, v)
+	}
+}
+
+func TestQL_Backup(t *testing.T) {
+	q, _ := openFile(t)
+	defer func() {
+		_ = q.db.Close()
+	}()
+	execTx(t, q.db, migration)
+	seedReferences(t, q.db)
+	orders, items := recordIDs(t, q.db, "Orders"), recordIDs(t, q.db, "Items")
+	customers := itemCustomers(t, q.db)
+	if len(customers) != 2 || customers[11] != 3 || customers[12] != 4 {
+		t.Fatalf("unexpected references %v", customers)
+	}
+
+	dest := filepath.Join(t.TempDir(), "backup.db")
+	if err := q.Backup(dest); err != nil {
+		t.Fatal(err)
+	}
+	db, err := sql.Open("ql", dest)
+	if err != nil {
+		t.Fatal(err)
+	}
+	b := FileWithPath(dest)
+	b.SetDB(db)
+	d, err := CompareSchemas(q, b)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !d.Empty() {
+		t.Errorf("expected matching schemas got %+v", d)
+	}
+	checkReferences(t, db, orders, items, customers)
+	if err = db.Close(); err != nil {
+		t.Fatal(err)
+	}
+
+	if err = q.Backup(dest); err == nil {
+		t.Error("expected an error for an existing destination")
+	}
+	q.SetBackupOverwrite(true)
+	if err = q.Backup(dest); err != nil {
+		t.Error(err)
+	}
+	if err = Memory().Backup(dest); err != ErrMemoryDatabase {
+		t.Errorf("expected %v got %v", ErrMemoryDatabase, err)
+	}
+	if err = File().Backup(dest); err != ErrNoPath {
+		t.Errorf("expected %v got %v", ErrNoPath, err)
+	}
+}
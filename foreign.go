@@ -0,0 +1,87 @@
+package ql
+
+import (
+	"fmt"
+	"sort"
+)
+
+// TrackForeignKey records that foreignKeyName relates tableName to another
+// table. ql has no foreign keys, so nothing is created in the database, the
+// dialect only remembers the name so HasForeignKey reports the key as present
+// and migrations do not try to add it again.
+//
+// Foreign keys are not safe to track while the dialect is in use.
+func (q *QL) TrackForeignKey(tableName, foreignKeyName string) {
+	if q.foreignKeys == nil {
+		q.foreignKeys = make(map[string]map[string]bool)
+	}
+	if q.foreignKeys[tableName] == nil {
+		q.foreignKeys[tableName] = make(map[string]bool)
+	}
+	q.foreignKeys[tableName][foreignKeyName] = true
+}
+
+// ListTrackedForeignKeys returns the names of the foreign keys tracked for
+// tableName, ordered by name.
+func (q *QL) ListTrackedForeignKeys(tableName string) []string {
+	var names []string
+	for name := range q.foreignKeys[tableName] {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names
+}
+
+// Reference describes a foreign key like relation ql does not enforce: every
+// non NULL value of ChildColumn in ChildTable is expected to be a value of
+// ParentColumn in ParentTable. ParentColumn can be id().
+type Reference struct {
+	// Name identifies the reference in the result of ValidateAllReferences.
+	// When empty the name given by BuildForeignKeyName is used.
+	Name string
+
+	ChildTable   string
+	ChildColumn  string
+	ParentTable  string
+	ParentColumn string
+}
+
+func (q *QL) referenceName(ref Reference) string {
+	if ref.Name != "" {
+		return ref.Name
+	}
+	return q.BuildForeignKeyName(ref.ChildTable, ref.ChildColumn, ref.ParentTable)
+}
+
+// ValidateReference returns the number of records of the child table whose
+// value does not match any record of the parent table. Records with a NULL
+// value refer to nothing and are not counted.
+func (q *QL) ValidateReference(ref Reference) (int64, error) {
+	if q.db == nil {
+		return 0, ErrNoDB
+	}
+	parent := ref.ParentColumn
+	if parent != "id()" {
+		parent = q.Quote(parent)
+	}
+	child := q.Quote(ref.ChildColumn)
+	query := fmt.Sprintf("SELECT count() FROM %s WHERE %s IS NOT NULL && %s NOT IN (SELECT %s FROM %s)",
+		q.Quote(ref.ChildTable), child, child, parent, q.Quote(ref.ParentTable))
+	return scalarInt64(q.conn(), query)
+}
+
+// ValidateAllReferences runs ValidateReference for each of refs and maps the
+// reference names to their number of dangling child records, which gives an
+// integrity check of the whole database. Valid references are reported with
+// a count of zero.
+func (q *QL) ValidateAllReferences(refs []Reference) (map[string]int64, error) {
+	o := make(map[string]int64, len(refs))
+	for _, ref := range refs {
+		n, err := q.ValidateReference(ref)
+		if err != nil {
+			return nil, fmt.Errorf("ql: reference %s: %v", q.referenceName(ref), err)
+		}
+		o[q.referenceName(ref)] = n
+	}
+	return o, nil
+}
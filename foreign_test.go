@@ -0,0 +1,70 @@
+package ql
+
+import (
+	"reflect"
+	"testing"
+)
+
+func TestQL_ListTrackedForeignKeys(t *testing.T) {
+	q := Memory()
+	if q.HasForeignKey("Items", "items_order_id_orders_id_foreign") {
+		t.Error("expected to be false")
+	}
+	q.TrackForeignKey("Items", "items_product_id_products_id_foreign")
+	q.TrackForeignKey("Items", "items_order_id_orders_id_foreign")
+	q.TrackForeignKey("Orders", "orders_customer_id_customers_id_foreign")
+	q.TrackForeignKey("Items", "items_order_id_orders_id_foreign")
+
+	exp := []string{"items_order_id_orders_id_foreign", "items_product_id_products_id_foreign"}
+	if o := q.ListTrackedForeignKeys("Items"); !reflect.DeepEqual(o, exp) {
+		t.Errorf("expected %v got %v", exp, o)
+	}
+	exp = []string{"orders_customer_id_customers_id_foreign"}
+	if o := q.ListTrackedForeignKeys("Orders"); !reflect.DeepEqual(o, exp) {
+		t.Errorf("expected %v got %v", exp, o)
+	}
+	if o := q.ListTrackedForeignKeys("Customers"); len(o) != 0 {
+		t.Errorf("expected no foreign keys got %v", o)
+	}
+	if !q.HasForeignKey("Items", "items_order_id_orders_id_foreign") {
+		t.Error("expected to be true")
+	}
+	if q.HasForeignKey("Orders", "items_order_id_orders_id_foreign") {
+		t.Error("expected to be false")
+	}
+}
+
+func TestQL_ValidateAllReferences(t *testing.T) {
+	q := openMemory(t)
+	execTx(t, q.db, migration)
+	execTx(t, q.db, `
+BEGIN TRANSACTION;
+	CREATE TABLE Customers (ID int64, Name string);
+	INSERT INTO Customers VALUES (1, "a"), (2, "b");
+	INSERT INTO Orders (CustomerID) VALUES (1), (2), (2), (7), (NULL);
+	INSERT INTO Items (OrderID) SELECT id() FROM Orders WHERE CustomerID == 2;
+	INSERT INTO Items (OrderID, ProductID) VALUES (-1, 10), (-2, 10);
+COMMIT;
+`)
+	refs := []Reference{
+		{ChildTable: "Orders", ChildColumn: "CustomerID", ParentTable: "Customers", ParentColumn: "ID"},
+		{Name: "items_order", ChildTable: "Items", ChildColumn: "OrderID", ParentTable: "Orders", ParentColumn: "id()"},
+		{Name: "items_product", ChildTable: "Items", ChildColumn: "ProductID", ParentTable: "Items", ParentColumn: "ProductID"},
+	}
+	o, err := q.ValidateAllReferences(refs)
+	if err != nil {
+		t.Fatal(err)
+	}
+	exp := map[string]int64{
+		"Orders_CustomerID_Customers_foreign": 1,
+		"items_order":                         2,
+		"items_product":                       0,
+	}
+	if !reflect.DeepEqual(o, exp) {
+		t.Errorf("expected %v got %v", exp, o)
+	}
+	_, err = q.ValidateAllReferences([]Reference{{Name: "missing", ChildTable: "Nope", ChildColumn: "ID", ParentTable: "Orders", ParentColumn: "id()"}})
+	if err == nil {
+		t.Error("expected an error for a missing table")
+	}
+}
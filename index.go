@@ -0,0 +1,297 @@
+package ql
+
+import (
+	"fmt"
+	"strings"
+
+	"github.com/akamajoris/ngorm/regexes"
+)
+
+// HasIndexOnColumns returns true if tableName has an index over exactly
+// columns, in that order. The id() pseudo column can be one of the columns.
+func (q *QL) HasIndexOnColumns(tableName string, columns []string) bool {
+	if q.db == nil {
+		return false
+	}
+	idx, err := indexes(q.conn(), tableName)
+	if err != nil {
+		return false
+	}
+	for _, i := range idx {
+		if sameColumns(i.Exprs, columns) {
+			return true
+		}
+	}
+	return false
+}
+
+func sameColumns(a, b []string) bool {
+	if len(a) != len(b) {
+		return false
+	}
+	for i := range a {
+		if a[i] != b[i] {
+			return false
+		}
+	}
+	return true
+}
+
+func containsString(a []string, s string) bool {
+	for _, v := range a {
+		if v == s {
+			return true
+		}
+	}
+	return false
+}
+
+// CreateIndex creates the index indexName over columns of tableName. A unique
+// index rejects records whose values for columns are already used by another
+// record.
+func (q *QL) CreateIndex(tableName, indexName string, columns []string, unique bool) error {
+	if len(columns) == 0 {
+		return fmt.Errorf("ql: index %s has no columns", indexName)
+	}
+	i := q.newIndex(tableName, indexName, columns, unique)
+	return q.transaction(func(tx queryer) error {
+		_, err := tx.Exec(createIndexSQL(i))
+		return err
+	})
+}
+
+// newIndex returns the description of the index indexName over columns of
+// tableName, quoting the columns but not id().
+func (q *QL) newIndex(tableName, indexName string, columns []string, unique bool) index {
+	i := index{Name: indexName, Table: tableName, Unique: unique}
+	for _, c := range columns {
+		if c != "id()" {
+			c = q.Quote(c)
+		}
+		i.Exprs = append(i.Exprs, c)
+	}
+	return i
+}
+
+// IndexName returns the name the dialect gives to the index over columns of
+// tableName, like the ones created by EnsureExpectedIndexes: the table and
+// column names joined by underscores and ending with _idx, for instance
+// books_author_id_title_idx. Like ngorm does for key names, every run of
+// characters other than letters becomes a single underscore, so an index over
+// id() is named <table>_id_idx.
+func (q *QL) IndexName(tableName string, columns []string) string {
+	name := tableName + "_" + strings.Join(columns, "_") + "_idx"
+	return q.identifier(regexes.KeyName.ReplaceAllString(name, "_"))
+}
+
+// EnsureExpectedIndexes creates the indexes that are missing from the
+// database. tables maps table names to the column sets that should be indexed,
+// a column set that is already covered by an index, whatever its name, is left
+// alone. Created indexes are named after the table and columns.
+func (q *QL) EnsureExpectedIndexes(tables map[string][][]string) error {
+	for tableName, sets := range tables {
+		for _, columns := range sets {
+			if q.HasIndexOnColumns(tableName, columns) {
+				continue
+			}
+			err := q.CreateIndex(tableName, q.IndexName(tableName, columns), columns, false)
+			if err != nil {
+				return err
+			}
+		}
+	}
+	return nil
+}
+
+// VerifyIndexes returns the column sets of expected that are not covered by
+// an index of tableName, in the order of expected. Unlike
+// EnsureExpectedIndexes it only reports, the database is left untouched.
+func (q *QL) VerifyIndexes(tableName string, expected [][]string) (missing [][]string, err error) {
+	if q.db == nil {
+		return nil, ErrNoDB
+	}
+	if !q.HasTable(tableName) {
+		return nil, fmt.Errorf("ql: table %s does not exist", tableName)
+	}
+	for _, columns := range expected {
+		if !q.HasIndexOnColumns(tableName, columns) {
+			missing = append(missing, columns)
+		}
+	}
+	return missing, nil
+}
+
+// UnindexedTables returns the user tables that have no index at all, ordered
+// by name. ql scans every record of such a table whenever it is filtered.
+func (q *QL) UnindexedTables() ([]string, error) {
+	names, err := q.ListTables()
+	if err != nil {
+		return nil, err
+	}
+	var o []string
+	for _, name := range names {
+		idx, err := q.ListIndexes(name)
+		if err != nil {
+			return nil, err
+		}
+		if len(idx) == 0 {
+			o = append(o, name)
+		}
+	}
+	return o, nil
+}
+
+// QueryPattern describes how a query filters a table, see SuggestIndexOrder.
+type QueryPattern struct {
+	// Equal lists the columns the query compares for equality.
+	Equal []string
+
+	// Range lists the columns the query compares with <, >, BETWEEN and the
+	// like, or orders by.
+	Range []string
+
+	// Weight is how often the query runs compared to the others. Zero counts
+	// as one.
+	Weight int
+}
+
+// SuggestIndexOrder returns columns of tableName ordered to best serve
+// patterns with a single compound index. It only advises, no index is
+// created.
+//
+// An index helps a query for a prefix of its columns: each column of the
+// prefix must be compared for equality by the query, except the last one which
+// may be a range. The order is built greedily, the next column is the one
+// usable by the largest weight of patterns that can still use the index,
+// preferring equality over range and then the order of columns. A pattern
+// that uses a column as a range can not use the columns that follow.
+func (q *QL) SuggestIndexOrder(tableName string, columns []string, patterns []QueryPattern) ([]string, error) {
+	if q.db == nil {
+		return nil, ErrNoDB
+	}
+	if len(columns) == 0 {
+		return nil, fmt.Errorf("ql: index on %s has no columns", tableName)
+	}
+	cols, err := q.ListColumns(tableName)
+	if err != nil {
+		return nil, err
+	}
+	known := make(map[string]bool)
+	for _, c := range cols {
+		known[c.Name] = true
+	}
+	for _, c := range columns {
+		if c != "id()" && !known[c] {
+			return nil, fmt.Errorf("ql: table %s has no column %s", tableName, c)
+		}
+	}
+	active := make([]bool, len(patterns))
+	for i := range active {
+		active[i] = true
+	}
+	remaining := append([]string(nil), columns...)
+	var o []string
+	for len(remaining) > 0 {
+		best, bestScore, bestEqual := 0, -1, -1
+		for k, c := range remaining {
+			score, equal := 0, 0
+			for i, p := range patterns {
+				if !active[i] {
+					continue
+				}
+				w := p.Weight
+				if w == 0 {
+					w = 1
+				}
+				switch {
+				case containsString(p.Equal, c):
+					score += w
+					equal += w
+				case containsString(p.Range, c):
+					score += w
+				}
+			}
+			if score > bestScore || score == bestScore && equal > bestEqual {
+				best, bestScore, bestEqual = k, score, equal
+			}
+		}
+		c := remaining[best]
+		o = append(o, c)
+		remaining = append(remaining[:best], remaining[best+1:]...)
+		for i, p := range patterns {
+			active[i] = active[i] && containsString(p.Equal, c)
+		}
+	}
+	return o, nil
+}
+
+// DuplicateValuesError is returned by EnsureUniqueKey when a column can not be
+// made unique because some of its values are used by several records.
+type DuplicateValuesError struct {
+	Table  string
+	Column string
+	Values []interface{}
+}
+
+func (e *DuplicateValuesError) Error() string {
+	return fmt.Sprintf("ql: %s.%s has duplicate values %v", e.Table, e.Column, e.Values)
+}
+
+// EnsureUniqueKey makes columnName of tableName behave like a key, by creating
+// a unique index over it named after IndexName. Nothing is done when a unique
+// index over the column alone exists. When records share a value a
+// *DuplicateValuesError listing the shared values is returned and no index is
+// created. NULL values are not considered duplicates.
+func (q *QL) EnsureUniqueKey(tableName, columnName string) error {
+	if q.db == nil {
+		return ErrNoDB
+	}
+	idx, err := indexes(q.conn(), tableName)
+	if err != nil {
+		return err
+	}
+	col := q.newIndex(tableName, "", []string{columnName}, true).Exprs
+	for _, i := range idx {
+		if i.Unique && sameColumns(i.Exprs, col) {
+			return nil
+		}
+	}
+	dup, err := q.duplicateValues(tableName, columnName)
+	if err != nil {
+		return err
+	}
+	if len(dup) > 0 {
+		return &DuplicateValuesError{Table: tableName, Column: columnName, Values: dup}
+	}
+	return q.CreateIndex(tableName, q.IndexName(tableName, []string{columnName}), []string{columnName}, true)
+}
+
+// duplicateValues returns the values of columnName used by more than one
+// record of tableName.
+func (q *QL) duplicateValues(tableName, columnName string) ([]interface{}, error) {
+	typ, err := q.ColumnType(tableName, columnName)
+	if err != nil {
+		return nil, err
+	}
+	query := fmt.Sprintf("SELECT %[2]s FROM (SELECT %[2]s, count() AS n FROM %[1]s WHERE %[2]s IS NOT NULL GROUP BY %[2]s) WHERE n > 1 ORDER BY %[2]s",
+		q.Quote(tableName), q.Quote(columnName))
+	rows, err := q.conn().Query(query)
+	if err != nil {
+		return nil, err
+	}
+	defer func() {
+		_ = rows.Close()
+	}()
+	var o []interface{}
+	for rows.Next() {
+		var v interface{}
+		if err = rows.Scan(&v); err != nil {
+			return nil, err
+		}
+		if v, err = decodeValue(typ, v); err != nil {
+			return nil, err
+		}
+		o = append(o, v)
+	}
+	return o, rows.Err()
+}
@@ -0,0 +1,223 @@
+package ql
+
+import (
+	"reflect"
+	"testing"
+)
+
+func TestQL_HasIndexOnColumns(t *testing.T) {
+	q := openMemory(t)
+	execTx(t, q.db, migration)
+	execTx(t, q.db, "CREATE INDEX OrdersCustomerDate ON Orders (CustomerID, Date)")
+	sample := []struct {
+		columns []string
+		exists  bool
+	}{
+		{[]string{"id()"}, true},
+		{[]string{"Date"}, true},
+		{[]string{"CustomerID", "Date"}, true},
+		{[]string{"Date", "CustomerID"}, false},
+		{[]string{"CustomerID"}, false},
+	}
+	for _, v := range sample {
+		if o := q.HasIndexOnColumns("Orders", v.columns); o != v.exists {
+			t.Errorf("%v: expected %v got %v", v.columns, v.exists, o)
+		}
+	}
+}
+
+func TestQL_CreateIndex(t *testing.T) {
+	q := openMemory(t)
+	execTx(t, q.db, migration)
+	err := q.CreateIndex("Items", "ItemsProduct", []string{"ProductID"}, true)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !q.HasIndex("Items", "ItemsProduct") {
+		t.Error("expected index ItemsProduct")
+	}
+	execTx(t, q.db, "INSERT INTO Items VALUES (1, 1, 1)")
+	tx, err := q.db.Begin()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err = tx.Exec("INSERT INTO Items VALUES (2, 1, 1)"); err == nil {
+		t.Error("expected the unique index to reject the record")
+	}
+	_ = tx.Rollback()
+	if err = q.CreateIndex("Items", "ItemsNothing", nil, false); err == nil {
+		t.Error("expected an error")
+	}
+}
+
+func TestQL_EnsureExpectedIndexes(t *testing.T) {
+	q := openMemory(t)
+	execTx(t, q.db, migration)
+	err := q.EnsureExpectedIndexes(map[string][][]string{
+		"Orders": {{"id()"}, {"Date"}, {"CustomerID"}},
+		"Items":  {{"id()"}, {"OrderID"}, {"ProductID", "Qty"}},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, v := range []struct{ table, index string }{
+		{"Orders", "Orders_CustomerID_idx"},
+		{"Items", "Items_id_idx"},
+		{"Items", "Items_ProductID_Qty_idx"},
+	} {
+		if !q.HasIndex(v.table, v.index) {
+			t.Errorf("expected index %s on %s", v.index, v.table)
+		}
+	}
+	idx, err := indexes(q.db, "Orders")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(idx) != 3 {
+		t.Errorf("expected 3 indexes on Orders got %v", idx)
+	}
+	idx, err = indexes(q.db, "Items")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(idx) != 3 {
+		t.Errorf("expected 3 indexes on Items got %v", idx)
+	}
+}
+
+func TestQL_VerifyIndexes(t *testing.T) {
+	q := openMemory(t)
+	execTx(t, q.db, migration)
+	execTx(t, q.db, "CREATE INDEX ItemsProduct ON Items (ProductID, Qty)")
+	missing, err := q.VerifyIndexes("Items", [][]string{
+		{"OrderID"},
+		{"Qty"},
+		{"ProductID", "Qty"},
+		{"id()"},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	exp := [][]string{{"Qty"}, {"id()"}}
+	if !reflect.DeepEqual(missing, exp) {
+		t.Errorf("expected %v got %v", exp, missing)
+	}
+	missing, err = q.VerifyIndexes("Orders", [][]string{{"id()"}, {"Date"}})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(missing) != 0 {
+		t.Errorf("expected no missing indexes got %v", missing)
+	}
+	if _, err = q.VerifyIndexes("Missing", [][]string{{"id()"}}); err == nil {
+		t.Error("expected an error for a missing table")
+	}
+}
+
+func TestQL_UnindexedTables(t *testing.T) {
+	q := openMemory(t)
+	execTx(t, q.db, migration)
+	execTx(t, q.db, "CREATE TABLE Logs (Message string)")
+	o, err := q.UnindexedTables()
+	if err != nil {
+		t.Fatal(err)
+	}
+	exp := []string{"Logs"}
+	if !reflect.DeepEqual(o, exp) {
+		t.Errorf("expected %v got %v", exp, o)
+	}
+}
+
+func TestQL_SuggestIndexOrder(t *testing.T) {
+	q := openMemory(t)
+	execTx(t, q.db, migration)
+	columns := []string{"Date", "CustomerID"}
+	sample := []struct {
+		patterns []QueryPattern
+		exp      []string
+	}{
+		// no patterns keep the given order.
+		{nil, []string{"Date", "CustomerID"}},
+		// CustomerID == $1 && Date > $2 uses both columns only when
+		// CustomerID leads.
+		{[]QueryPattern{{Equal: []string{"CustomerID"}, Range: []string{"Date"}}}, []string{"CustomerID", "Date"}},
+		// the frequent range query on Date outweighs the customer lookups.
+		{[]QueryPattern{
+			{Range: []string{"Date"}, Weight: 5},
+			{Equal: []string{"CustomerID"}, Weight: 2},
+		}, []string{"Date", "CustomerID"}},
+		{[]QueryPattern{
+			{Range: []string{"Date"}},
+			{Equal: []string{"CustomerID"}},
+		}, []string{"CustomerID", "Date"}},
+	}
+	for _, v := range sample {
+		o, err := q.SuggestIndexOrder("Orders", columns, v.patterns)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if !reflect.DeepEqual(o, v.exp) {
+			t.Errorf("%v: expected %v got %v", v.patterns, v.exp, o)
+		}
+	}
+	if _, err := q.SuggestIndexOrder("Orders", []string{"Missing"}, nil); err == nil {
+		t.Error("expected an error for an unknown column")
+	}
+	if _, err := q.SuggestIndexOrder("Orders", nil, nil); err == nil {
+		t.Error("expected an error without columns")
+	}
+}
+
+func TestQL_IndexName(t *testing.T) {
+	q := Memory()
+	sample := []struct {
+		table   string
+		columns []string
+		exp     string
+	}{
+		{"books", []string{"title"}, "books_title_idx"},
+		{"books", []string{"author_id", "title"}, "books_author_id_title_idx"},
+		{"authors", []string{"id()"}, "authors_id_idx"},
+		{"books", []string{"__weird  name"}, "books_weird_name_idx"},
+	}
+	for _, v := range sample {
+		o := q.IndexName(v.table, v.columns)
+		if o != v.exp {
+			t.Errorf("%s %v: expected %s got %s", v.table, v.columns, v.exp, o)
+		}
+		if o2 := q.IndexName(v.table, v.columns); o2 != o {
+			t.Errorf("expected a stable name got %s and %s", o, o2)
+		}
+		if !identifierRe.MatchString(o) {
+			t.Errorf("expected a valid identifier got %s", o)
+		}
+	}
+}
+
+func TestQL_EnsureUniqueKey(t *testing.T) {
+	q := openMemory(t)
+	execTx(t, q.db, "CREATE TABLE Users (UUID string, Email string)")
+	execTx(t, q.db, `INSERT INTO Users VALUES ("a", "x@example.com"), ("b", "y@example.com"), ("c", "x@example.com"), (NULL, "z@example.com"), (NULL, "y@example.com")`)
+	if err := q.EnsureUniqueKey("Users", "UUID"); err != nil {
+		t.Fatal(err)
+	}
+	if !q.HasIndex("Users", "Users_UUID_idx") {
+		t.Error("expected the unique index to be created")
+	}
+	if err := q.EnsureUniqueKey("Users", "UUID"); err != nil {
+		t.Errorf("expected an existing key to be kept got %v", err)
+	}
+
+	err := q.EnsureUniqueKey("Users", "Email")
+	e, ok := err.(*DuplicateValuesError)
+	if !ok {
+		t.Fatalf("expected a *DuplicateValuesError got %v", err)
+	}
+	exp := []interface{}{"x@example.com", "y@example.com"}
+	if !reflect.DeepEqual(e.Values, exp) {
+		t.Errorf("expected %v got %v", exp, e.Values)
+	}
+	if q.HasIndex("Users", "Users_Email_idx") {
+		t.Error("expected no index on duplicate values")
+	}
+}
@@ -0,0 +1,248 @@
+package ql
+
+import (
+	"database/sql"
+	"fmt"
+	"sort"
+	"strings"
+
+	"github.com/akamajoris/ngorm/model"
+)
+
+// InsertSQL returns the INSERT statement that adds a record to tableName with
+// the given column values, and its arguments. Columns are ordered by name so
+// the statement is the same for the same columns.
+//
+// InsertSQL does not read the table, bigint, bigrat and duration values are
+// passed in a form database/sql accepts and converted back by their
+// placeholder, other values are bound as they are.
+func (q *QL) InsertSQL(tableName string, values map[string]interface{}) (string, []interface{}, error) {
+	columns, row, err := insertRow(tableName, values)
+	if err != nil {
+		return "", nil, err
+	}
+	return q.batchInsertSQL(tableName, columns, nil, [][]interface{}{row})
+}
+
+// insertRow splits values into the sorted column names and their values.
+func insertRow(tableName string, values map[string]interface{}) ([]string, []interface{}, error) {
+	if len(values) == 0 {
+		return nil, nil, fmt.Errorf("ql: insert into %s: no columns", tableName)
+	}
+	columns := sortedKeys(values)
+	row := make([]interface{}, len(columns))
+	for i, c := range columns {
+		row[i] = values[c]
+	}
+	return columns, row, nil
+}
+
+// ExecInsert executes the statement of InsertSQL on db, with every value
+// converted to the type of its column. See SetAutoTransaction for running it
+// on a handle that is not a transaction. When tableName is one of the tables
+// of SetAutoCreateTables or has a row limit the statement always runs in a
+// transaction, in which the table is created or its limit checked.
+func (q *QL) ExecInsert(db model.SQLCommon, tableName string, values map[string]interface{}) (sql.Result, error) {
+	columns, row, err := insertRow(tableName, values)
+	if err != nil {
+		return nil, err
+	}
+	rows := [][]interface{}{row}
+	_, create := q.autoCreateTables[tableName]
+	_, limit := q.rowLimits[tableName]
+	if !create && !limit {
+		query, args, err := q.typedInsertSQL(q.observed(db), tableName, columns, rows)
+		if err != nil {
+			return nil, err
+		}
+		return q.execWrite(db, query, args...)
+	}
+	var res sql.Result
+	err = q.transactionOn(db, func(tx queryer) error {
+		if err := q.beforeInsert(tx, tableName, 1); err != nil {
+			return err
+		}
+		query, args, err := q.typedInsertSQL(tx, tableName, columns, rows)
+		if err != nil {
+			return err
+		}
+		res, err = tx.Exec(query, args...)
+		return err
+	})
+	if err != nil {
+		return nil, err
+	}
+	return res, nil
+}
+
+// beforeInsert runs in the transaction of an insert of n records into
+// tableName. It creates the table when SetAutoCreateTables asks for it and
+// enforces the limit set with SetRowLimit.
+func (q *QL) beforeInsert(tx queryer, tableName string, n int) error {
+	if err := q.autoCreateTable(tx, tableName); err != nil {
+		return err
+	}
+	return q.checkRowLimit(tx, tableName, n)
+}
+
+func sortedKeys(m map[string]interface{}) []string {
+	keys := make([]string, 0, len(m))
+	for k := range m {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+	return keys
+}
+
+// BatchInsert inserts rows into the columns of tableName with a single multi
+// row INSERT statement. Each row holds one value per column, in the order of
+// columns, which is converted to the type of the column. The statement runs in
+// a transaction on db, unless db already is one.
+func (q *QL) BatchInsert(db model.SQLCommon, tableName string, columns []string, rows [][]interface{}) error {
+	if _, _, err := q.batchInsertSQL(tableName, columns, nil, rows); err != nil || len(rows) == 0 {
+		return err
+	}
+	return q.transactionOn(db, func(tx queryer) error {
+		if err := q.beforeInsert(tx, tableName, len(rows)); err != nil {
+			return err
+		}
+		query, args, err := q.typedInsertSQL(tx, tableName, columns, rows)
+		if err != nil {
+			return err
+		}
+		_, err = tx.Exec(query, args...)
+		return err
+	})
+}
+
+// BatchInsertReturningIDs works like BatchInsert and returns the id() assigned
+// to each of rows, in the order of rows.
+//
+// ql has no RETURNING clause, but it hands out id() values in increasing order,
+// so the records added by the statement are the ones with an id() above the
+// largest one found before it ran. Both queries run in the same transaction so
+// no other record can get in between.
+func (q *QL) BatchInsertReturningIDs(db model.SQLCommon, tableName string, columns []string, rows [][]interface{}) ([]int64, error) {
+	if _, _, err := q.batchInsertSQL(tableName, columns, nil, rows); err != nil || len(rows) == 0 {
+		return nil, err
+	}
+	var ids []int64
+	err := q.transactionOn(db, func(tx queryer) error {
+		if err := q.beforeInsert(tx, tableName, len(rows)); err != nil {
+			return err
+		}
+		query, args, err := q.typedInsertSQL(tx, tableName, columns, rows)
+		if err != nil {
+			return err
+		}
+		// max(id()) is always NULL in ql, hence the ordering.
+		var last int64
+		err = tx.QueryRow(fmt.Sprintf("SELECT id() FROM %s ORDER BY id() DESC LIMIT 1", q.Quote(tableName))).Scan(&last)
+		if err != nil && err != sql.ErrNoRows {
+			return err
+		}
+		if _, err = tx.Exec(query, args...); err != nil {
+			return err
+		}
+		ids, err = idsAfter(tx, q.Quote(tableName), last)
+		if err != nil {
+			return err
+		}
+		if len(ids) != len(rows) {
+			return fmt.Errorf("ql: inserted %d records into %s but found %d new ids", len(rows), tableName, len(ids))
+		}
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+	return ids, nil
+}
+
+func idsAfter(db queryer, tableName string, id int64) ([]int64, error) {
+	rows, err := db.Query(fmt.Sprintf("SELECT id() FROM %s WHERE id() > $1 ORDER BY id()", tableName), id)
+	if err != nil {
+		return nil, err
+	}
+	defer func() {
+		_ = rows.Close()
+	}()
+	var ids []int64
+	for rows.Next() {
+		var id int64
+		if err = rows.Scan(&id); err != nil {
+			return nil, err
+		}
+		ids = append(ids, id)
+	}
+	return ids, rows.Err()
+}
+
+// typedInsertSQL returns the statement of batchInsertSQL with the types of the
+// columns read through db.
+func (q *QL) typedInsertSQL(db queryer, tableName string, names []string, rows [][]interface{}) (string, []interface{}, error) {
+	cols, err := columns(db, tableName)
+	if err != nil {
+		return "", nil, err
+	}
+	byName := make(map[string]string, len(cols))
+	for _, c := range cols {
+		byName[c.Name] = c.Type
+	}
+	types := make([]string, len(names))
+	for i, name := range names {
+		types[i] = byName[name]
+	}
+	return q.batchInsertSQL(tableName, names, types, rows)
+}
+
+// batchInsertSQL returns the INSERT statement for rows and its arguments. No
+// rows give an empty statement.
+//
+// Values are encoded like in ImportRows and their placeholders convert them to
+// types, which holds the type of each column. A column without a type, or nil
+// types, is only converted for the values encodeValue turns into text or
+// integers.
+func (q *QL) batchInsertSQL(tableName string, columns, types []string, rows [][]interface{}) (string, []interface{}, error) {
+	if len(columns) == 0 {
+		return "", nil, fmt.Errorf("ql: insert into %s: no columns", tableName)
+	}
+	if len(rows) == 0 {
+		return "", nil, nil
+	}
+	names := make([]string, len(columns))
+	for i, c := range columns {
+		names[i] = q.Quote(c)
+	}
+	var b strings.Builder
+	fmt.Fprintf(&b, "INSERT INTO %s (%s) VALUES ", q.Quote(tableName), strings.Join(names, ", "))
+	var args []interface{}
+	for i, row := range rows {
+		if len(row) != len(columns) {
+			return "", nil, fmt.Errorf("ql: insert into %s: row %d has %d values for %d columns",
+				tableName, i, len(row), len(columns))
+		}
+		if i > 0 {
+			b.WriteString(", ")
+		}
+		vars := make([]string, len(row))
+		for j, v := range row {
+			v, err := q.bindArg(tableName, columns[j], v)
+			if err != nil {
+				return "", nil, fmt.Errorf("ql: insert into %s: row %d, column %s: %v", tableName, i, columns[j], err)
+			}
+			args = append(args, v)
+			typ := valueType(row[j])
+			if types != nil && types[j] != "" {
+				typ = types[j]
+			}
+			if typ != "" {
+				vars[j] = q.bindExpr(typ, len(args))
+			} else {
+				vars[j] = q.BindVar(len(args))
+			}
+		}
+		fmt.Fprintf(&b, "(%s)", strings.Join(vars, ", "))
+	}
+	return b.String(), args, nil
+}
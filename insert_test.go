@@ -0,0 +1,168 @@
+package ql
+
+import (
+	"math/big"
+	"testing"
+	"time"
+)
+
+func TestQL_BatchInsert(t *testing.T) {
+	q := openMemory(t)
+	execTx(t, q.db, migration)
+	cols := []string{"OrderID", "ProductID", "Qty"}
+	err := q.BatchInsert(q.db, "Items", cols, [][]interface{}{
+		{int64(1), int64(10), int64(2)},
+		{int64(1), int64(11), int64(3)},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	n, err := q.CountRows("Items")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if n != 2 {
+		t.Errorf("expected 2 got %d", n)
+	}
+	err = q.BatchInsert(q.db, "Items", cols, [][]interface{}{{int64(1)}})
+	if err == nil {
+		t.Error("expected an error for a short row")
+	}
+}
+
+func TestQL_BatchInsertReturningIDs(t *testing.T) {
+	q := openMemory(t)
+	execTx(t, q.db, migration)
+	execTx(t, q.db, "INSERT INTO Items VALUES (9, 9, 9)")
+	cols := []string{"OrderID", "ProductID", "Qty"}
+	rows := [][]interface{}{
+		{int64(1), int64(10), int64(1)},
+		{int64(2), int64(20), int64(2)},
+		{int64(3), int64(30), int64(3)},
+	}
+	tx, err := q.db.Begin()
+	if err != nil {
+		t.Fatal(err)
+	}
+	ids, err := q.BatchInsertReturningIDs(&Tx{Tx: tx}, "Items", cols, rows)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err = tx.Commit(); err != nil {
+		t.Fatal(err)
+	}
+	if len(ids) != 3 {
+		t.Fatalf("expected 3 ids got %v", ids)
+	}
+	for i, id := range ids {
+		if i > 0 && id <= ids[i-1] {
+			t.Errorf("expected increasing ids got %v", ids)
+		}
+		var qty int64
+		err = q.db.QueryRow("SELECT Qty FROM Items WHERE id() == $1", id).Scan(&qty)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if qty != int64(i+1) {
+			t.Errorf("id %d: expected qty %d got %d", id, i+1, qty)
+		}
+	}
+
+	ids, err = q.BatchInsertReturningIDs(q.db, "Items", cols, rows[:1])
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(ids) != 1 {
+		t.Errorf("expected 1 id got %v", ids)
+	}
+}
+
+func TestQL_InsertSQL(t *testing.T) {
+	q := openMemory(t)
+	s, args, err := q.InsertSQL("Items", map[string]interface{}{"Qty": int64(3), "OrderID": int64(1)})
+	if err != nil {
+		t.Fatal(err)
+	}
+	exp := "INSERT INTO Items (OrderID, Qty) VALUES ($1, $2)"
+	if s != exp {
+		t.Errorf("expected %s got %s", exp, s)
+	}
+	if len(args) != 2 || args[0] != int64(1) || args[1] != int64(3) {
+		t.Errorf("expected [1 3] got %v", args)
+	}
+	execTx(t, q.db, migration)
+	execTx(t, q.db, s, args...)
+	if _, _, err = q.InsertSQL("Items", nil); err == nil {
+		t.Error("expected an error")
+	}
+}
+
+func TestQL_BatchInsertTypes(t *testing.T) {
+	q := openMemory(t)
+	execTx(t, q.db, "CREATE TABLE amounts (small int8, total bigint, share bigrat, took duration)")
+	cols := []string{"small", "total", "share", "took"}
+	total, _ := new(big.Int).SetString("123456789012345678901234567890", 10)
+	err := q.BatchInsert(q.db, "amounts", cols, [][]interface{}{
+		{int64(1), total, big.NewRat(1, 3), 2 * time.Second},
+		{int64(2), nil, nil, nil},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	ids, err := q.BatchInsertReturningIDs(q.db, "amounts", cols, [][]interface{}{
+		{int64(3), big.NewInt(-7), big.NewRat(-5, 4), time.Minute},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(ids) != 1 {
+		t.Fatalf("expected 1 id got %v", ids)
+	}
+	rows, err := q.ExportRows("amounts")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(rows) != 3 {
+		t.Fatalf("expected 3 rows got %d", len(rows))
+	}
+	for _, v := range []struct {
+		row      int
+		small    int8
+		total    string
+		share    string
+		duration time.Duration
+	}{
+		{0, 1, total.String(), "1/3", 2 * time.Second},
+		{2, 3, "-7", "-5/4", time.Minute},
+	} {
+		row := rows[v.row]
+		if row["small"] != int64(v.small) {
+			t.Errorf("row %d: expected %d got %#v", v.row, v.small, row["small"])
+		}
+		if i, ok := row["total"].(*big.Int); !ok || i.String() != v.total {
+			t.Errorf("row %d: expected %s got %#v", v.row, v.total, row["total"])
+		}
+		if r, ok := row["share"].(*big.Rat); !ok || r.String() != v.share {
+			t.Errorf("row %d: expected %s got %#v", v.row, v.share, row["share"])
+		}
+		if row["took"] != v.duration {
+			t.Errorf("row %d: expected %v got %#v", v.row, v.duration, row["took"])
+		}
+	}
+	if row := rows[1]; row["total"] != nil || row["share"] != nil || row["took"] != nil {
+		t.Errorf("expected NULL values got %v", row)
+	}
+	if rows[2][IDColumn] != ids[0] {
+		t.Errorf("expected id %d got %v", ids[0], rows[2][IDColumn])
+	}
+
+	s, args, err := q.InsertSQL("amounts", map[string]interface{}{"share": big.NewRat(1, 2), "took": time.Hour})
+	if err != nil {
+		t.Fatal(err)
+	}
+	exp := "INSERT INTO amounts (share, took) VALUES (bigrat($1), duration($2))"
+	if s != exp {
+		t.Errorf("expected %s got %s", exp, s)
+	}
+	execTx(t, q.db, s, args...)
+}
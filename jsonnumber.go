@@ -0,0 +1,92 @@
+package ql
+
+import (
+	"encoding/json"
+	"fmt"
+	"math/big"
+	"reflect"
+)
+
+var jsonNumberType = reflect.TypeOf(json.Number(""))
+
+// SetJSONNumberAsString makes DataTypeOf map json.Number fields to string
+// columns. By default they are mapped to bigrat columns which keep the exact
+// value and compare numerically, string columns keep the exact text instead.
+func (q *QL) SetJSONNumberAsString(ok bool) {
+	q.jsonNumberAsString = ok
+}
+
+func (q *QL) jsonNumberType() string {
+	if q.jsonNumberAsString {
+		return "string"
+	}
+	return "bigrat"
+}
+
+// BindJSONNumber returns the argument to pass for n. For bigrat columns the
+// number is passed as text which must be converted in the statement, as in
+//
+//	INSERT INTO Payloads (Amount) VALUES (bigrat($1))
+func (q *QL) BindJSONNumber(n json.Number) (interface{}, error) {
+	if _, ok := new(big.Rat).SetString(n.String()); !ok {
+		return nil, fmt.Errorf("ql: invalid number %q", n)
+	}
+	return n.String(), nil
+}
+
+// ScanJSONNumber decodes src, a value read from a json.Number column, into dst.
+// Numbers read from bigrat columns are written in decimal notation with as
+// many digits as needed to represent them exactly.
+func (q *QL) ScanJSONNumber(src interface{}, dst *json.Number) error {
+	var s string
+	switch v := src.(type) {
+	case []byte:
+		s = string(v)
+	case string:
+		s = v
+	default:
+		return fmt.Errorf("ql: cannot scan %T into json.Number", src)
+	}
+	if q.jsonNumberAsString {
+		*dst = json.Number(s)
+		return nil
+	}
+	r, ok := new(big.Rat).SetString(s)
+	if !ok {
+		return fmt.Errorf("ql: invalid bigrat %q", s)
+	}
+	digits, ok := decimalDigits(r.Denom())
+	if !ok {
+		return fmt.Errorf("ql: %s has no finite decimal representation", r.RatString())
+	}
+	*dst = json.Number(r.FloatString(digits))
+	return nil
+}
+
+// decimalDigits returns the number of fractional digits needed to write the
+// fraction with denominator d in decimal notation, which is possible when d
+// has no prime factors other than 2 and 5.
+func decimalDigits(d *big.Int) (int, bool) {
+	d = new(big.Int).Set(d)
+	var twos, fives int
+	two, five := big.NewInt(2), big.NewInt(5)
+	m := new(big.Int)
+	for d.Sign() > 0 {
+		if q, r := new(big.Int).QuoRem(d, two, m); r.Sign() == 0 {
+			d, twos = q, twos+1
+			continue
+		}
+		if q, r := new(big.Int).QuoRem(d, five, m); r.Sign() == 0 {
+			d, fives = q, fives+1
+			continue
+		}
+		break
+	}
+	if d.Cmp(big.NewInt(1)) != 0 {
+		return 0, false
+	}
+	if twos > fives {
+		return twos, true
+	}
+	return fives, true
+}
@@ -0,0 +1,63 @@
+package ql
+
+import (
+	"encoding/json"
+	"testing"
+)
+
+type Payload struct {
+	ID     int64
+	Amount json.Number
+}
+
+func TestQL_DataTypeOfJSONNumber(t *testing.T) {
+	q := Memory()
+	for _, f := range modelFields(t, &Payload{}) {
+		if f.Name != "Amount" {
+			continue
+		}
+		s, err := q.DataTypeOf(f)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if s != "bigrat" {
+			t.Errorf("expected bigrat got %s", s)
+		}
+		q.SetJSONNumberAsString(true)
+		s, err = q.DataTypeOf(f)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if s != "string" {
+			t.Errorf("expected string got %s", s)
+		}
+	}
+}
+
+func TestQL_ScanJSONNumber(t *testing.T) {
+	q := openMemory(t)
+	execTx(t, q.db, "CREATE TABLE payloads (Amount bigrat)")
+	n := json.Number("3.14159265358979323846264338327950288")
+	v, err := q.BindJSONNumber(n)
+	if err != nil {
+		t.Fatal(err)
+	}
+	execTx(t, q.db, "INSERT INTO payloads VALUES (bigrat($1))", v)
+	var src interface{}
+	if err = q.db.QueryRow("SELECT Amount FROM payloads").Scan(&src); err != nil {
+		t.Fatal(err)
+	}
+	var o json.Number
+	if err = q.ScanJSONNumber(src, &o); err != nil {
+		t.Fatal(err)
+	}
+	if o != n {
+		t.Errorf("expected %s got %s", n, o)
+	}
+	if _, err = q.BindJSONNumber("12abc"); err == nil {
+		t.Error("expected an error")
+	}
+	if err = q.ScanJSONNumber("1/3", &o); err == nil {
+		t.Error("expected an error")
+	}
+}
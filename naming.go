@@ -0,0 +1,29 @@
+package ql
+
+import (
+	"fmt"
+	"hash/fnv"
+)
+
+// SetMaxIdentifierLength limits the length of the names the dialect
+// generates, like the ones from BuildForeignKeyName. Longer names are cut and
+// suffixed with a hash of the full name so that distinct names stay distinct.
+// Zero or a negative n means no limit, which is the default.
+func (q *QL) SetMaxIdentifierLength(n int) {
+	q.maxIdentifierLength = n
+}
+
+// identifier shortens a generated name to the configured maximum length.
+func (q *QL) identifier(name string) string {
+	n := q.maxIdentifierLength
+	if n <= 0 || len(name) <= n {
+		return name
+	}
+	h := fnv.New32a()
+	_, _ = h.Write([]byte(name))
+	suffix := fmt.Sprintf("%08x", h.Sum32())
+	if n <= len(suffix)+1 {
+		return suffix[:n]
+	}
+	return name[:n-len(suffix)-1] + "_" + suffix
+}
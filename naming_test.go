@@ -0,0 +1,38 @@
+package ql
+
+import (
+	"strings"
+	"testing"
+)
+
+func TestQL_SetMaxIdentifierLength(t *testing.T) {
+	q := Memory()
+	table := strings.Repeat("customer_accounts", 4)
+	long := q.BuildForeignKeyName(table, "billing_address", "id")
+	if !strings.HasPrefix(long, table) {
+		t.Errorf("expected the full name got %s", long)
+	}
+
+	q.SetMaxIdentifierLength(32)
+	o := q.BuildForeignKeyName(table, "billing_address", "id")
+	if len(o) != 32 {
+		t.Errorf("expected 32 characters got %d (%s)", len(o), o)
+	}
+	if o != q.BuildForeignKeyName(table, "billing_address", "id") {
+		t.Error("expected the name to be stable")
+	}
+	other := q.BuildForeignKeyName(table, "shipping_address", "id")
+	if other == o {
+		t.Errorf("expected distinct names got %s", o)
+	}
+	short := q.BuildForeignKeyName("users", "city", "id")
+	if short != "users_city_id_foreign" {
+		t.Errorf("expected users_city_id_foreign got %s", short)
+	}
+
+	q.SetMaxIdentifierLength(4)
+	o = q.BuildForeignKeyName(table, "billing_address", "id")
+	if len(o) != 4 {
+		t.Errorf("expected 4 characters got %d (%s)", len(o), o)
+	}
+}
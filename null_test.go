@@ -0,0 +1,71 @@
+//go:build go1.22
+
+package ql
+
+import (
+	"database/sql"
+	"testing"
+)
+
+type Profile struct {
+	Age      sql.Null[int]
+	Nickname sql.Null[string]
+}
+
+func TestQL_DataTypeOf_sqlNull(t *testing.T) {
+	q := openMemory(t)
+	q.SetNullByDefault(false)
+	fields := modelFields(t, &Profile{})
+	for k, exp := range []string{"int64", "string"} {
+		typ, err := q.DataTypeOf(fields[k])
+		if err != nil {
+			t.Fatal(err)
+		}
+		if canonicalType(typ) != exp {
+			t.Errorf("%s: expected %s got %s", fields[k].Name, exp, typ)
+		}
+	}
+
+	execTx(t, q.db, "CREATE TABLE Profiles (Age int64, Nickname string)")
+	for _, p := range []Profile{
+		{Age: sql.Null[int]{V: 42, Valid: true}, Nickname: sql.Null[string]{V: "gopher", Valid: true}},
+		{},
+	} {
+		age, err := BindNull(p.Age)
+		if err != nil {
+			t.Fatal(err)
+		}
+		nickname, err := BindNull(&p.Nickname)
+		if err != nil {
+			t.Fatal(err)
+		}
+		execTx(t, q.db, "INSERT INTO Profiles VALUES ($1, $2)", age, nickname)
+	}
+	rows, err := q.db.Query("SELECT Age, Nickname FROM Profiles ORDER BY id()")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer func() {
+		_ = rows.Close()
+	}()
+	var got []Profile
+	for rows.Next() {
+		var p Profile
+		if err := rows.Scan(&p.Age, &p.Nickname); err != nil {
+			t.Fatal(err)
+		}
+		got = append(got, p)
+	}
+	if len(got) != 2 {
+		t.Fatalf("expected 2 profiles got %d", len(got))
+	}
+	if got[0].Age.V != 42 || !got[0].Age.Valid || got[0].Nickname.V != "gopher" || !got[0].Nickname.Valid {
+		t.Errorf("expected the values to round trip got %+v", got[0])
+	}
+	if got[1].Age.Valid || got[1].Nickname.Valid {
+		t.Errorf("expected NULL values got %+v", got[1])
+	}
+	if _, err := BindNull(42); err == nil {
+		t.Error("expected an error for a value that is not a sql.Null")
+	}
+}
@@ -28,8 +28,41 @@ import (
 // irrelevant assuming the SQLCommon interface is the handle over the open
 // database.
 type QL struct {
-	name string
-	db   model.SQLCommon
+	name    string
+	path    string
+	db      model.SQLCommon
+	builder SQLBuilder
+
+	blobAsBase64        bool
+	transformers        map[string]Transformer
+	maxIdentifierLength int
+	nonFiniteAsNull     bool
+	retry               RetryPolicy
+	slowThreshold       time.Duration
+	slowHandler         func(query string, elapsed time.Duration)
+	backupOverwrite     bool
+	uniformInt64        bool
+	onConnect           func(db model.SQLCommon) error
+	connectErr          error
+	foreignKeys         map[string]map[string]bool
+	notNullByDefault    bool
+	jsonNumberAsString  bool
+	dummyTable          string
+	recorder            *Recorder
+	maxBlobSize         int64
+	enforceBlobSize     bool
+	autoTransaction     bool
+	untypedColumn       string
+	strictIDField       bool
+	warningHandler      func(message string)
+	onBegin             func()
+	onCommit            func()
+	onRollback          func()
+	autoCreateTables    map[string]TableDef
+	rowLimits           map[string]int64
+	features            *featureCache
+	ratAsDecimal        bool
+	ratPlaces           int
 }
 
 // Memory returns the dialect for in memory ql database. This is not persistent
@@ -54,20 +87,43 @@ func (q *QL) GetName() string {
 	return q.name
 }
 
-// SetDB set db for dialect
+// SetDB set db for dialect. The hook set with SetOnConnect is called with db.
+// The features found by ProbeFeature are probed again for the new handle.
 func (q *QL) SetDB(db model.SQLCommon) {
 	q.db = db
+	q.connectErr = nil
+	q.features = &featureCache{}
+	if q.onConnect != nil && db != nil {
+		q.connectErr = q.onConnect(db)
+	}
+}
+
+// SetOnConnect sets a function that is called at the end of SetDB with the new
+// handle, to create tables or indexes the application always needs for
+// instance. SetDB can not return errors so the error of fn is kept and
+// reported by Ping.
+func (q *QL) SetOnConnect(fn func(db model.SQLCommon) error) {
+	q.onConnect = fn
 }
 
 // BindVar return the placeholder for actual values in SQL statements, in many dbs it is "?", Postgres using $1
+//
+// Arguments are numbered from 1, BindVar(1) is $1 and refers to the first
+// argument of the statement.
 func (q QL) BindVar(i int) string {
-	return "$" + strconv.FormatInt(int64(i), 10)
+	return q.sqlBuilder().BindVar(i)
+}
+
+// BindVarFrom returns the placeholder for the i'th argument of a fragment whose
+// arguments follow base arguments of the statement it is part of, that is
+// BindVar(base + i). BindVarFrom(10, 2) is $12.
+func (q QL) BindVarFrom(base, i int) string {
+	return q.BindVar(base + i)
 }
 
 // Quote quotes field name to avoid SQL parsing exceptions by using a reserved word as a field name
 func (q *QL) Quote(key string) string {
-	//return fmt.Sprintf(`"%s"`, key)
-	return key
+	return q.sqlBuilder().Quote(key)
 }
 
 //PrimaryKey implements dialects.Dialect interface. This is supposed to return a
@@ -99,91 +155,150 @@ func (q *QL) DataTypeOf(field *model.StructField) (string, error) {
 		reflect.Float64,
 		reflect.String:
 		sqlType = dataValue.Kind().String()
+		if q.uniformInt64 && uniformKinds[dataValue.Kind()] {
+			sqlType = "int64"
+		}
+		if dataValue.Type() == jsonNumberType {
+			sqlType = q.jsonNumberType()
+		}
 	case reflect.Struct:
 		switch dataValue.Interface().(type) {
 		case time.Time:
 			sqlType = "time"
 		case big.Int:
 			sqlType = "bigint"
-		case big.Rat:
+		case big.Rat, Decimal:
 			sqlType = "bigrat"
 		}
+		// named types like type Timestamp time.Time do not match the case
+		// above but are still stored as time.
+		if sqlType == "" && dataValue.Type().ConvertibleTo(timeType) {
+			sqlType = "time"
+		}
+	case reflect.Array:
+		t, err := arrayType(field, dataValue.Type())
+		if err != nil {
+			return "", err
+		}
+		sqlType = t
+	case reflect.Interface:
+		sqlType = q.untypedColumn
 	default:
 		if _, ok := dataValue.Interface().([]byte); ok {
 			sqlType = "blob"
+			if q.blobAsBase64 {
+				sqlType = "string"
+			}
 		}
 	}
+	if sqlType == "" && dataValue.Type() == timeSliceType {
+		return "", fmt.Errorf("invalid sql type %s for ql, store the values in a side table, see TimeSliceTableSQL", dataValue.Type())
+	}
 	if sqlType == "" {
 		return "", fmt.Errorf("invalid sql type %s (%s) for ql", dataValue.Type().Name(), dataValue.Kind().String())
 	}
 
-	if strings.TrimSpace(additionalType) == "" {
-		return sqlType, nil
+	// default:now is rendered as the ql function returning the current time.
+	if def := field.TagSettings["DEFAULT"]; sqlType == "time" && strings.EqualFold(def, "now") {
+		additionalType = strings.TrimSuffix(additionalType, def) + "now()"
 	}
-
-	return fmt.Sprintf("%v %v", sqlType, additionalType), nil
+	if q.notNullByDefault && !field.IsPrimaryKey && !hasNullability(field) && !isNullType(field.Struct.Type) {
+		additionalType = strings.TrimSpace("NOT NULL " + additionalType)
+	}
+	if strings.TrimSpace(additionalType) != "" {
+		sqlType = fmt.Sprintf("%v %v", sqlType, additionalType)
+	}
+	if !isValidQLType(sqlType) {
+		return "", fmt.Errorf("invalid sql type %q for ql", sqlType)
+	}
+	return sqlType, nil
 }
 
 // HasIndex check has index or not
 func (q *QL) HasIndex(tableName string, indexName string) bool {
 	query := "select count() from __Index where Name=$1  && TableName=$2"
-	var count int
-	_ = q.db.QueryRow(query, indexName, tableName).Scan(&count)
+	count, _ := scalarInt64(q.conn(), query, indexName, tableName)
 	return count > 0
 }
 
-// HasForeignKey check has foreign key or not
+// HasForeignKey check has foreign key or not. ql has no foreign keys, only the
+// ones tracked with TrackForeignKey are reported.
 func (q *QL) HasForeignKey(tableName string, foreignKeyName string) bool {
-	return false
+	return q.foreignKeys[tableName][foreignKeyName]
 }
 
 // RemoveIndex remove index
 func (q *QL) RemoveIndex(tableName string, indexName string) error {
-	tx, err := q.db.Begin()
-	if err != nil {
+	return q.transaction(func(tx queryer) error {
+		_, err := tx.Exec(fmt.Sprintf("DROP INDEX %v", indexName))
 		return err
-	}
-	_, err = tx.Exec(fmt.Sprintf("DROP INDEX %v", indexName))
-	if err != nil {
-		return err
-	}
-	return tx.Commit()
+	})
 }
 
 // HasTable check has table or not
 func (q *QL) HasTable(tableName string) bool {
 	query := "select count() from __Table where Name=$1"
-	var count int
-	_ = q.db.QueryRow(query, tableName).Scan(&count)
+	count, _ := scalarInt64(q.conn(), query, tableName)
 	return count > 0
 }
 
 // HasColumn check has column or not
 func (q *QL) HasColumn(tableName string, columnName string) bool {
 	query := "select count() from __Column where Name=$1  && TableName=$2"
-	var count int
-	_ = q.db.QueryRow(query, columnName, tableName).Scan(&count)
+	count, _ := scalarInt64(q.conn(), query, columnName, tableName)
 	return count > 0
 }
 
+// HasColumns is like HasColumn for several columns at once, it reports which of
+// columnNames tableName has with a single query.
+func (q *QL) HasColumns(tableName string, columnNames []string) (map[string]bool, error) {
+	if q.db == nil {
+		return nil, ErrNoDB
+	}
+	cols, err := columns(q.conn(), tableName)
+	if err != nil {
+		return nil, err
+	}
+	o := make(map[string]bool, len(columnNames))
+	for _, name := range columnNames {
+		o[name] = false
+	}
+	for _, c := range cols {
+		if _, ok := o[c.Name]; ok {
+			o[c.Name] = true
+		}
+	}
+	return o, nil
+}
+
 // LimitAndOffsetSQL return generated SQL with Limit and Offset, as mssql has special case
 func (q *QL) LimitAndOffsetSQL(limit, offset interface{}) (sql string) {
 	if limit != nil {
 		if parsedLimit, err := strconv.ParseInt(fmt.Sprint(limit), 0, 0); err == nil && parsedLimit > 0 {
-			sql += fmt.Sprintf(" LIMIT %d", parsedLimit)
+			sql += " " + q.sqlBuilder().Limit(parsedLimit)
 		}
 	}
 	if offset != nil {
 		if parsedOffset, err := strconv.ParseInt(fmt.Sprint(offset), 0, 0); err == nil && parsedOffset > 0 {
-			sql += fmt.Sprintf(" OFFSET %d", parsedOffset)
+			sql += " " + q.sqlBuilder().Offset(parsedOffset)
 		}
 	}
 	return
 }
 
 // SelectFromDummyTable return select values, for most dbs, `SELECT values` just works, mysql needs `SELECT value FROM DUAL`
+//
+// ql evaluates SELECT without FROM so this is empty unless set with
+// SetDummyTable.
 func (q *QL) SelectFromDummyTable() string {
-	return ""
+	return q.dummyTable
+}
+
+// SetDummyTable sets the value returned by SelectFromDummyTable, for instance
+// "FROM dual" with a table of that name holding a single record. An empty
+// expr restores the default.
+func (q *QL) SetDummyTable(expr string) {
+	q.dummyTable = expr
 }
 
 // LastInsertIDReturningSuffix ost dbs support LastInsertId, but postgres needs to use `RETURNING`
@@ -195,7 +310,7 @@ func (q *QL) LastInsertIDReturningSuffix(tableName, columnName string) string {
 func (q *QL) BuildForeignKeyName(tableName, field, dest string) string {
 	keyName := fmt.Sprintf("%s_%s_%s_foreign", tableName, field, dest)
 	keyName = regexes.KeyName.ReplaceAllString(keyName, "_")
-	return keyName
+	return q.identifier(keyName)
 }
 
 // CurrentDatabase return current database name
@@ -211,3 +326,31 @@ func (q *QL) CurrentDatabase() string {
 func (q QL) QueryFieldName(name string) string {
 	return ""
 }
+
+// DialectCapabilities describes what the ql dialect supports, so portable code
+// can branch on it instead of on the dialect name.
+type DialectCapabilities struct {
+	// SupportsForeignKeys is false, ql has no foreign key constraints.
+	SupportsForeignKeys bool
+
+	// SupportsPrimaryKey is false, ql has no PRIMARY KEY clause.
+	SupportsPrimaryKey bool
+
+	// HasImplicitRowID is true, every record has a unique id().
+	HasImplicitRowID bool
+
+	// SupportsReturning is false, there is no RETURNING clause and the id()
+	// of an inserted record is reported by LastInsertId.
+	SupportsReturning bool
+
+	// SupportsSavepoints is false, a transaction can not be partially rolled
+	// back.
+	SupportsSavepoints bool
+}
+
+// Capabilities returns the features supported by ql.
+func (q *QL) Capabilities() DialectCapabilities {
+	return DialectCapabilities{
+		HasImplicitRowID: true,
+	}
+}
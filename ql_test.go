@@ -2,6 +2,7 @@ package ql
 
 import (
 	"database/sql"
+	"errors"
 	"reflect"
 	"testing"
 
@@ -31,6 +32,33 @@ BEGIN TRANSACTION;
 COMMIT;
 `
 
+func openMemory(t *testing.T) *QL {
+	db, err := sql.Open("ql-mem", t.Name()+".db")
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() {
+		_ = db.Close()
+	})
+	q := Memory()
+	q.SetDB(db)
+	return q
+}
+
+func execTx(t *testing.T, db model.SQLCommon, query string, args ...interface{}) {
+	tx, err := db.Begin()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err = tx.Exec(query, args...); err != nil {
+		_ = tx.Rollback()
+		t.Fatal(err)
+	}
+	if err = tx.Commit(); err != nil {
+		t.Fatal(err)
+	}
+}
+
 func TestDialect(t *testing.T) {
 	d := Memory()
 	if d.GetName() != "ql-mem" {
@@ -221,3 +249,128 @@ func TestQL_DataTypeOf(t *testing.T) {
 		}
 	}
 }
+
+func TestQL_SetOnConnect(t *testing.T) {
+	db, err := sql.Open("ql-mem", t.Name()+".db")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer func() {
+		_ = db.Close()
+	}()
+	q := Memory()
+	q.SetOnConnect(func(db model.SQLCommon) error {
+		tx, err := db.Begin()
+		if err != nil {
+			return err
+		}
+		if _, err = tx.Exec("CREATE TABLE IF NOT EXISTS Settings (Name string, Value string)"); err != nil {
+			_ = tx.Rollback()
+			return err
+		}
+		return tx.Commit()
+	})
+	q.SetDB(db)
+	if err = q.Ping(); err != nil {
+		t.Fatal(err)
+	}
+	if !q.HasTable("Settings") {
+		t.Error("expected the table to be created")
+	}
+
+	hookErr := errors.New("setup failed")
+	q.SetOnConnect(func(db model.SQLCommon) error {
+		return hookErr
+	})
+	q.SetDB(db)
+	if err = q.Ping(); err != hookErr {
+		t.Errorf("expected %v got %v", hookErr, err)
+	}
+}
+
+func TestQL_SetDummyTable(t *testing.T) {
+	q := openMemory(t)
+	if o := q.SelectFromDummyTable(); o != "" {
+		t.Errorf("expected an empty string got %s", o)
+	}
+	q.SetDummyTable("FROM dual")
+	if o := q.SelectFromDummyTable(); o != "FROM dual" {
+		t.Errorf("expected FROM dual got %s", o)
+	}
+	execTx(t, q.db, "CREATE TABLE dual (x bool); INSERT INTO dual VALUES (true)")
+	var n int
+	err := q.db.QueryRow("SELECT 1 " + q.SelectFromDummyTable()).Scan(&n)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if n != 1 {
+		t.Errorf("expected 1 got %d", n)
+	}
+	q.SetDummyTable("")
+	if o := q.SelectFromDummyTable(); o != "" {
+		t.Errorf("expected an empty string got %s", o)
+	}
+}
+
+func TestQL_Capabilities(t *testing.T) {
+	q := openMemory(t)
+	c := q.Capabilities()
+	if c.SupportsForeignKeys || c.SupportsPrimaryKey || c.SupportsReturning || c.SupportsSavepoints {
+		t.Errorf("unexpected capabilities %+v", c)
+	}
+	if !c.HasImplicitRowID {
+		t.Error("expected an implicit row id")
+	}
+	if s := q.PrimaryKey([]string{"id"}); s != "" {
+		t.Errorf("expected no primary key clause got %s", s)
+	}
+	if s := q.LastInsertIDReturningSuffix("Orders", "id"); s != "" {
+		t.Errorf("expected no returning suffix got %s", s)
+	}
+	execTx(t, q.db, migration)
+	tx, err := q.db.Begin()
+	if err != nil {
+		t.Fatal(err)
+	}
+	res, err := tx.Exec("INSERT INTO Orders (CustomerID) VALUES (1)")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err = tx.Commit(); err != nil {
+		t.Fatal(err)
+	}
+	id, err := res.LastInsertId()
+	if err != nil {
+		t.Fatal(err)
+	}
+	o, err := q.ScalarInt64("SELECT id() FROM Orders WHERE CustomerID == 1")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if o != id {
+		t.Errorf("expected id() %d got %d", id, o)
+	}
+	// nothing checks that the referenced order exists.
+	execTx(t, q.db, "CREATE TABLE refs (OrderID int)")
+	execTx(t, q.db, "INSERT INTO refs VALUES (12345)")
+}
+
+func TestQL_HasColumns(t *testing.T) {
+	q := openMemory(t)
+	execTx(t, q.db, migration)
+	o, err := q.HasColumns("Items", []string{"OrderID", "Qty", "Price", "Date"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	exp := map[string]bool{"OrderID": true, "Qty": true, "Price": false, "Date": false}
+	if !reflect.DeepEqual(o, exp) {
+		t.Errorf("expected %v got %v", exp, o)
+	}
+	o, err = q.HasColumns("Missing", []string{"OrderID"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !reflect.DeepEqual(o, map[string]bool{"OrderID": false}) {
+		t.Errorf("expected OrderID to be missing got %v", o)
+	}
+}
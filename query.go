@@ -0,0 +1,45 @@
+package ql
+
+import (
+	"errors"
+	"strings"
+)
+
+// ErrExplainNotSupported is returned by Explain when the ql version in use does
+// not know the EXPLAIN statement.
+var ErrExplainNotSupported = errors.New("ql: EXPLAIN is not supported by this ql version")
+
+// Explain returns the plan ql uses to execute query, one step per line.
+//
+// ql reports problems with the explained statement as a plan line, those are
+// returned as an error instead.
+func (q *QL) Explain(query string, args ...interface{}) (string, error) {
+	if q.db == nil {
+		return "", ErrNoDB
+	}
+	rows, err := q.conn().Query("EXPLAIN "+query, args...)
+	if err != nil {
+		if strings.Contains(err.Error(), "unexpected EXPLAIN") {
+			return "", ErrExplainNotSupported
+		}
+		return "", err
+	}
+	defer func() {
+		_ = rows.Close()
+	}()
+	var plan []string
+	for rows.Next() {
+		var line string
+		if err = rows.Scan(&line); err != nil {
+			return "", err
+		}
+		if strings.HasPrefix(line, "ERROR: ") {
+			return "", errors.New("ql: " + strings.TrimPrefix(line, "ERROR: "))
+		}
+		plan = append(plan, line)
+	}
+	if err = rows.Err(); err != nil {
+		return "", err
+	}
+	return strings.Join(plan, "\n"), nil
+}
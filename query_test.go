@@ -0,0 +1,25 @@
+package ql
+
+import (
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestQL_Explain(t *testing.T) {
+	q := openMemory(t)
+	execTx(t, q.db, migration)
+	plan, err := q.Explain("SELECT * FROM Orders WHERE Date > $1", time.Now())
+	if err != nil {
+		t.Fatal(err)
+	}
+	if plan == "" {
+		t.Fatal("expected a plan")
+	}
+	if !strings.Contains(plan, "OrdersDate") {
+		t.Errorf("expected the plan to use index OrdersDate got %s", plan)
+	}
+	if _, err = q.Explain("SELECT * FROM Missing"); err == nil {
+		t.Error("expected an error")
+	}
+}
@@ -0,0 +1,46 @@
+package ql
+
+import "sync"
+
+// Statement is a statement run by the dialect together with its arguments.
+type Statement struct {
+	Query string
+	Args  []interface{}
+}
+
+// Recorder collects the statements the dialect runs itself, in the order they
+// run. It is safe for concurrent use.
+type Recorder struct {
+	mu    sync.Mutex
+	stmts []Statement
+}
+
+func (r *Recorder) record(query string, args []interface{}) {
+	r.mu.Lock()
+	r.stmts = append(r.stmts, Statement{Query: query, Args: append([]interface{}(nil), args...)})
+	r.mu.Unlock()
+}
+
+// Statements returns the statements recorded so far.
+func (r *Recorder) Statements() []Statement {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	return append([]Statement(nil), r.stmts...)
+}
+
+// Reset returns the statements recorded so far and starts a new log.
+func (r *Recorder) Reset() []Statement {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	s := r.stmts
+	r.stmts = nil
+	return s
+}
+
+// SetStatementRecorder makes the dialect record the statements it runs itself,
+// like the introspection queries of HasTable or the DROP INDEX of RemoveIndex,
+// in r. Statements ngorm runs directly on the handle are not seen. Passing nil
+// stops recording.
+func (q *QL) SetStatementRecorder(r *Recorder) {
+	q.recorder = r
+}
@@ -0,0 +1,37 @@
+package ql
+
+import (
+	"reflect"
+	"testing"
+)
+
+func TestQL_SetStatementRecorder(t *testing.T) {
+	q := openMemory(t)
+	execTx(t, q.db, migration)
+	r := &Recorder{}
+	q.SetStatementRecorder(r)
+	q.HasTable("Orders")
+	if err := q.RemoveIndex("Orders", "OrdersDate"); err != nil {
+		t.Fatal(err)
+	}
+	exp := []Statement{
+		{Query: "select count() from __Table where Name=$1", Args: []interface{}{"Orders"}},
+		{Query: "DROP INDEX OrdersDate"},
+	}
+	o := r.Statements()
+	if !reflect.DeepEqual(o, exp) {
+		t.Errorf("expected %v got %v", exp, o)
+	}
+	if o = r.Reset(); len(o) != 2 {
+		t.Errorf("expected 2 statements got %v", o)
+	}
+	if o = r.Statements(); len(o) != 0 {
+		t.Errorf("expected an empty log got %v", o)
+	}
+
+	q.SetStatementRecorder(nil)
+	q.HasTable("Orders")
+	if o = r.Statements(); len(o) != 0 {
+		t.Errorf("expected nothing to be recorded got %v", o)
+	}
+}
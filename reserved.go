@@ -0,0 +1,50 @@
+package ql
+
+import (
+	"strings"
+
+	"github.com/akamajoris/ngorm/model"
+)
+
+// reservedWords are the keywords of the ql grammar, type names included. ql
+// matches keywords regardless of case.
+var reservedWords = map[string]bool{
+	"ADD": true, "ALTER": true, "AND": true, "AS": true, "ASC": true,
+	"BEGIN": true, "BETWEEN": true, "BIGINT": true, "BIGRAT": true,
+	"BLOB": true, "BOOL": true, "BY": true, "BYTE": true, "COLUMN": true,
+	"COMMIT": true, "COMPLEX128": true, "COMPLEX64": true, "CREATE": true,
+	"DEFAULT": true, "DELETE": true, "DESC": true, "DISTINCT": true,
+	"DROP": true, "DURATION": true, "EXISTS": true, "EXPLAIN": true,
+	"FALSE": true, "FLOAT": true, "FLOAT32": true, "FLOAT64": true,
+	"FROM": true, "FULL": true, "GROUP": true, "IF": true, "IN": true,
+	"INDEX": true, "INSERT": true, "INT": true, "INT16": true, "INT32": true,
+	"INT64": true, "INT8": true, "INTO": true, "IS": true, "JOIN": true,
+	"LEFT": true, "LIKE": true, "LIMIT": true, "NOT": true, "NULL": true,
+	"OFFSET": true, "ON": true, "OR": true, "ORDER": true, "OUTER": true,
+	"RIGHT": true, "ROLLBACK": true, "RUNE": true, "SELECT": true,
+	"SET": true, "STRING": true, "TABLE": true, "TIME": true,
+	"TRANSACTION": true, "TRUE": true, "TRUNCATE": true, "UINT": true,
+	"UINT16": true, "UINT32": true, "UINT64": true, "UINT8": true,
+	"UNIQUE": true, "UPDATE": true, "VALUES": true, "WHERE": true,
+}
+
+// isReservedWord returns true if name is a ql keyword.
+func isReservedWord(name string) bool {
+	return reservedWords[strings.ToUpper(name)]
+}
+
+// ReservedWordColumns returns the column names of fields that are ql keywords,
+// in field order. ql has no way of quoting identifiers, so such columns have to
+// be renamed, for instance with a column tag, before the table is created.
+func (q *QL) ReservedWordColumns(fields []*model.StructField) []string {
+	var names []string
+	for _, f := range fields {
+		if !f.IsNormal || f.IsIgnored {
+			continue
+		}
+		if name := q.ColumnName(f); isReservedWord(name) {
+			names = append(names, name)
+		}
+	}
+	return names
+}
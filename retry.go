@@ -0,0 +1,73 @@
+package ql
+
+import (
+	"errors"
+	"strings"
+	"syscall"
+	"time"
+)
+
+// RetryPolicy tells the dialect how to retry operations that fail because the
+// database is locked by someone else.
+type RetryPolicy struct {
+	// Attempts is the maximum number of times the operation is tried,
+	// including the first one.
+	Attempts int
+
+	// Backoff is the delay before the first retry, it doubles with every
+	// following one.
+	Backoff time.Duration
+}
+
+// SetRetryPolicy makes RemoveIndex and the other helpers that run their own
+// transaction retry it according to p when it fails with a transient lock
+// error. Other errors are returned immediately. The zero RetryPolicy, the
+// default, disables retrying.
+func (q *QL) SetRetryPolicy(p RetryPolicy) {
+	q.retry = p
+}
+
+// lockMessages are the texts of the errors reported when the database is held
+// by someone else: the ones of the lock file ql takes, of the OS and of
+// wrapping drivers.
+var lockMessages = []string{
+	"database is locked",
+	"database is busy",
+	"already locked",
+	"cannot acquire lock",
+	"resource temporarily unavailable",
+	"device or resource busy",
+}
+
+// isLockError returns true if err reports that the database is held by another
+// transaction or process, which is expected to go away. Only the known lock
+// messages match, an error merely naming a table like Blocks does not.
+func isLockError(err error) bool {
+	if err == nil {
+		return false
+	}
+	if errors.Is(err, syscall.EAGAIN) || errors.Is(err, syscall.EBUSY) {
+		return true
+	}
+	msg := strings.ToLower(err.Error())
+	for _, v := range lockMessages {
+		if strings.Contains(msg, v) {
+			return true
+		}
+	}
+	return false
+}
+
+// withRetry calls fn until it succeeds, fails with an error that is not a lock
+// error or the attempts of the retry policy are exhausted.
+func (q *QL) withRetry(fn func() error) error {
+	backoff := q.retry.Backoff
+	for attempt := 1; ; attempt++ {
+		err := fn()
+		if err == nil || attempt >= q.retry.Attempts || !isLockError(err) {
+			return err
+		}
+		time.Sleep(backoff)
+		backoff *= 2
+	}
+}
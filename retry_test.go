@@ -0,0 +1,105 @@
+package ql
+
+import (
+	"database/sql"
+	"errors"
+	"os"
+	"syscall"
+	"testing"
+	"time"
+)
+
+// lockedDB fails to begin the first failures transactions with err.
+type lockedDB struct {
+	*sql.DB
+	failures int
+	err      error
+	calls    int
+}
+
+func (l *lockedDB) Begin() (*sql.Tx, error) {
+	l.calls++
+	if l.calls <= l.failures {
+		return nil, l.err
+	}
+	return l.DB.Begin()
+}
+
+func TestQL_SetRetryPolicy(t *testing.T) {
+	q := openMemory(t)
+	execTx(t, q.db, migration)
+	db := &lockedDB{
+		DB:       q.db.(*sql.DB),
+		failures: 1,
+		err:      errors.New(`file "test.db" already locked`),
+	}
+	q.SetDB(db)
+
+	// without a policy the error is returned
+	if err := q.RemoveIndex("Orders", "OrdersID"); err != db.err {
+		t.Errorf("expected %v got %v", db.err, err)
+	}
+
+	db.calls = 0
+	q.SetRetryPolicy(RetryPolicy{Attempts: 3, Backoff: time.Millisecond})
+	if err := q.RemoveIndex("Orders", "OrdersID"); err != nil {
+		t.Fatal(err)
+	}
+	if db.calls != 2 {
+		t.Errorf("expected 2 attempts got %d", db.calls)
+	}
+	if q.HasIndex("Orders", "OrdersID") {
+		t.Error("expected the index to be removed")
+	}
+
+	// other errors are not retried
+	db.calls = 0
+	db.err = errors.New("ql: something else")
+	if err := q.RemoveIndex("Orders", "OrdersDate"); err != db.err {
+		t.Errorf("expected %v got %v", db.err, err)
+	}
+	if db.calls != 1 {
+		t.Errorf("expected 1 attempt got %d", db.calls)
+	}
+
+	// neither are errors that only mention a lock in an identifier
+	db.calls = 0
+	db.err = errors.New("table Blocks does not exist")
+	if err := q.RemoveIndex("Orders", "OrdersDate"); err != db.err {
+		t.Errorf("expected %v got %v", db.err, err)
+	}
+	if db.calls != 1 {
+		t.Errorf("expected 1 attempt got %d", db.calls)
+	}
+
+	// attempts are limited
+	db.calls = 0
+	db.failures = 5
+	db.err = errors.New("ql: database is locked")
+	if err := q.RemoveIndex("Orders", "OrdersDate"); err != db.err {
+		t.Errorf("expected %v got %v", db.err, err)
+	}
+	if db.calls != 3 {
+		t.Errorf("expected 3 attempts got %d", db.calls)
+	}
+}
+
+func TestIsLockError(t *testing.T) {
+	for _, v := range []struct {
+		err  error
+		lock bool
+	}{
+		{errors.New("database is locked"), true},
+		{errors.New(`file "/tmp/test.db" already locked`), true},
+		{errors.New("cannot acquire lock: resource temporarily unavailable"), true},
+		{&os.PathError{Op: "open", Path: "test.db", Err: syscall.EAGAIN}, true},
+		{errors.New("table Blocks does not exist"), false},
+		{errors.New("DROP INDEX: index ClockIdx does not exist"), false},
+		{errors.New("ql: request blocked by the application"), false},
+		{nil, false},
+	} {
+		if l := isLockError(v.err); l != v.lock {
+			t.Errorf("%v: expected %v got %v", v.err, v.lock, l)
+		}
+	}
+}
@@ -0,0 +1,296 @@
+package ql
+
+import (
+	"database/sql"
+	"errors"
+	"fmt"
+	"strings"
+
+	"github.com/akamajoris/ngorm/model"
+)
+
+// CountRows returns the number of records in tableName.
+func (q *QL) CountRows(tableName string) (int64, error) {
+	if q.db == nil {
+		return 0, ErrNoDB
+	}
+	return countRows(q.conn(), tableName)
+}
+
+func countRows(db queryer, tableName string) (int64, error) {
+	return scalarInt64(db, fmt.Sprintf("SELECT count() FROM %s", tableName))
+}
+
+// ErrRowLimitReached is returned when an insert would take a table beyond the
+// number of records set with SetRowLimit.
+var ErrRowLimitReached = errors.New("ql: row limit reached")
+
+// SetRowLimit caps the number of records of tableName at max, to bound the
+// size of the database. The helpers that insert records, like ExecInsert,
+// BatchInsert and ImportRows, then fail with ErrRowLimitReached instead of
+// going over the limit. Statements run by other means are not checked. A max
+// of 0 or less removes the limit.
+func (q *QL) SetRowLimit(tableName string, max int64) {
+	if max <= 0 {
+		delete(q.rowLimits, tableName)
+		return
+	}
+	if q.rowLimits == nil {
+		q.rowLimits = make(map[string]int64)
+	}
+	q.rowLimits[tableName] = max
+}
+
+// checkRowLimit returns ErrRowLimitReached if adding n records to tableName
+// would exceed its limit.
+func (q *QL) checkRowLimit(tx queryer, tableName string, n int) error {
+	max, ok := q.rowLimits[tableName]
+	if !ok {
+		return nil
+	}
+	count, err := countRows(tx, tableName)
+	if err != nil {
+		return err
+	}
+	if count+int64(n) > max {
+		return ErrRowLimitReached
+	}
+	return nil
+}
+
+// HasIDGaps returns true if the id() values of tableName are not contiguous,
+// which happens when records are deleted. It compares the number of records
+// with the range between the lowest and the highest id(). An empty table has
+// no gaps.
+func (q *QL) HasIDGaps(tableName string) (bool, error) {
+	if q.db == nil {
+		return false, ErrNoDB
+	}
+	db := q.conn()
+	// max(id()) and min(id()) are always NULL in ql, the bounds are taken from
+	// the first record in each direction instead.
+	first, err := scalarInt64(db, fmt.Sprintf("SELECT id() FROM %s ORDER BY id() LIMIT 1", tableName))
+	switch err {
+	case nil:
+	case ErrNoValue:
+		return false, nil
+	default:
+		return false, err
+	}
+	last, err := scalarInt64(db, fmt.Sprintf("SELECT id() FROM %s ORDER BY id() DESC LIMIT 1", tableName))
+	if err != nil {
+		return false, err
+	}
+	n, err := countRows(db, tableName)
+	if err != nil {
+		return false, err
+	}
+	return n != last-first+1, nil
+}
+
+// NumericStats returns the lowest, the highest and the average value of the
+// numeric column columnName of tableName, computed by a single query. NULL
+// values are skipped. When no record has a value, for instance because the
+// table is empty, ErrNoValue is returned.
+func (q *QL) NumericStats(tableName, columnName string) (min, max, avg float64, err error) {
+	typ, err := q.ColumnType(tableName, columnName)
+	if err != nil {
+		return 0, 0, 0, err
+	}
+	if !IsNumericType(typ) || numericTypes[canonicalType(typ)].family == familyComplex {
+		return 0, 0, 0, fmt.Errorf("ql: column %s of table %s is not a real number, it is %s", columnName, tableName, typ)
+	}
+	query := fmt.Sprintf("SELECT min(float64(%[1]s)), max(float64(%[1]s)), avg(float64(%[1]s)) FROM %[2]s", columnName, tableName)
+	var lo, hi, mean sql.NullFloat64
+	if err = q.conn().QueryRow(query).Scan(&lo, &hi, &mean); err != nil {
+		return 0, 0, 0, err
+	}
+	if !lo.Valid {
+		return 0, 0, 0, ErrNoValue
+	}
+	return lo.Float64, hi.Float64, mean.Float64, nil
+}
+
+// HasRows returns true if tableName has at least one record. Unlike CountRows
+// it stops at the first record it finds.
+func (q *QL) HasRows(tableName string) (bool, error) {
+	if q.db == nil {
+		return false, ErrNoDB
+	}
+	_, err := q.ScalarInt64(fmt.Sprintf("SELECT id() FROM %s LIMIT 1", tableName))
+	switch err {
+	case nil:
+		return true, nil
+	case ErrNoValue:
+		return false, nil
+	default:
+		return false, err
+	}
+}
+
+// execCounting executes query, which is expected to remove records from
+// tableName, and returns the number of records it touched.
+//
+// ql does not report affected rows for every statement, TRUNCATE TABLE for
+// instance is treated as DDL. When the result has no row count the difference
+// between the number of records before and after the statement is used
+// instead, which is accurate because both counts are taken inside tx.
+func execCounting(tx queryer, tableName, query string, args ...interface{}) (int64, error) {
+	before, err := countRows(tx, tableName)
+	if err != nil {
+		return 0, err
+	}
+	res, err := tx.Exec(query, args...)
+	if err != nil {
+		return 0, err
+	}
+	if n, err := res.RowsAffected(); err == nil {
+		return n, nil
+	}
+	after, err := countRows(tx, tableName)
+	if err != nil {
+		return 0, err
+	}
+	return before - after, nil
+}
+
+// Truncate removes all records from tableName and returns how many were
+// removed.
+func (q *QL) Truncate(tableName string) (int64, error) {
+	var n int64
+	err := q.transaction(func(tx queryer) error {
+		var err error
+		n, err = execCounting(tx, tableName, fmt.Sprintf("TRUNCATE TABLE %s", tableName))
+		return err
+	})
+	if err != nil {
+		return 0, err
+	}
+	return n, nil
+}
+
+// DeleteByIDs removes the records of tableName whose id() is one of ids and
+// returns how many were removed.
+func (q *QL) DeleteByIDs(tableName string, ids ...int64) (int64, error) {
+	if len(ids) == 0 {
+		return 0, nil
+	}
+	var n int64
+	err := q.transaction(func(tx queryer) error {
+		var err error
+		n, err = q.deleteIDs(tx, tableName, ids)
+		return err
+	})
+	if err != nil {
+		return 0, err
+	}
+	return n, nil
+}
+
+func (q *QL) deleteIDs(tx queryer, tableName string, ids []int64) (int64, error) {
+	args := make([]interface{}, len(ids))
+	vars := make([]string, len(ids))
+	for i, id := range ids {
+		args[i] = id
+		vars[i] = q.BindVar(i + 1)
+	}
+	query := fmt.Sprintf("DELETE FROM %s WHERE id() IN (%s)", tableName, strings.Join(vars, ", "))
+	return execCounting(tx, tableName, query, args...)
+}
+
+// DeduplicateRows removes the records of tableName that repeat the values of
+// keyColumns of an earlier record, keeping the one with the lowest id() for
+// each combination, and returns how many were removed. NULL values are
+// considered equal. It runs in a transaction on tx, unless tx already is one.
+func (q *QL) DeduplicateRows(tx model.SQLCommon, tableName string, keyColumns []string) (int64, error) {
+	if len(keyColumns) == 0 {
+		return 0, fmt.Errorf("ql: deduplicate %s: no key columns", tableName)
+	}
+	fields := []string{"id()"}
+	for _, c := range keyColumns {
+		fields = append(fields, q.Quote(c))
+	}
+	query := fmt.Sprintf("SELECT %s FROM %s ORDER BY id()", strings.Join(fields, ", "), tableName)
+	var n int64
+	err := q.transactionOn(tx, func(tx queryer) error {
+		dup, err := duplicateIDs(tx, query, len(keyColumns))
+		if err != nil || len(dup) == 0 {
+			return err
+		}
+		n, err = q.deleteIDs(tx, tableName, dup)
+		return err
+	})
+	if err != nil {
+		return 0, err
+	}
+	return n, nil
+}
+
+// duplicateIDs runs query, which selects id() followed by keys key columns in
+// id() order, and returns the ids of the records whose keys were already seen.
+func duplicateIDs(db queryer, query string, keys int) ([]int64, error) {
+	rows, err := db.Query(query)
+	if err != nil {
+		return nil, err
+	}
+	defer func() {
+		_ = rows.Close()
+	}()
+	seen := make(map[string]bool)
+	var dup []int64
+	for rows.Next() {
+		var id int64
+		v := make([]interface{}, keys)
+		ptr := []interface{}{&id}
+		for i := range v {
+			ptr = append(ptr, &v[i])
+		}
+		if err = rows.Scan(ptr...); err != nil {
+			return nil, err
+		}
+		var key strings.Builder
+		for _, x := range v {
+			if b, ok := x.([]byte); ok {
+				x = string(b)
+			}
+			fmt.Fprintf(&key, "%T:%v\x00", x, x)
+		}
+		if seen[key.String()] {
+			dup = append(dup, id)
+			continue
+		}
+		seen[key.String()] = true
+	}
+	return dup, rows.Err()
+}
+
+// CountDistinct returns the number of distinct non NULL values of columnName
+// in tableName. ql has no count(DISTINCT expr) so the distinct values are
+// counted with a subquery.
+func (q *QL) CountDistinct(tableName, columnName string) (int64, error) {
+	if q.db == nil {
+		return 0, ErrNoDB
+	}
+	if !q.HasColumn(tableName, columnName) {
+		return 0, fmt.Errorf("ql: column %s does not exist in table %s", columnName, tableName)
+	}
+	column := q.Quote(columnName)
+	query := fmt.Sprintf("SELECT count() FROM (SELECT DISTINCT %s FROM %s WHERE %s IS NOT NULL)",
+		column, q.Quote(tableName), column)
+	return q.ScalarInt64(query)
+}
+
+// ColumnCardinality returns the number of distinct non NULL values of
+// columnName, see CountDistinct, and the number of records of tableName. Their
+// ratio is the selectivity of a filter on the column: close to 1 the column
+// singles out few records and is worth indexing, close to 0 an index helps
+// little.
+func (q *QL) ColumnCardinality(tableName, columnName string) (distinct int64, total int64, err error) {
+	if distinct, err = q.CountDistinct(tableName, columnName); err != nil {
+		return 0, 0, err
+	}
+	if total, err = q.CountRows(tableName); err != nil {
+		return 0, 0, err
+	}
+	return distinct, total, nil
+}
@@ -0,0 +1,283 @@
+package ql
+
+import (
+	"reflect"
+	"testing"
+)
+
+func TestQL_Truncate(t *testing.T) {
+	q := openMemory(t)
+	execTx(t, q.db, migration)
+	execTx(t, q.db, "INSERT INTO Items VALUES (1, 1, 1), (2, 2, 2), (3, 3, 3)")
+
+	// TRUNCATE TABLE is reported as DDL so the driver has no affected rows.
+	n, err := q.Truncate("Items")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if n != 3 {
+		t.Errorf("expected 3 got %d", n)
+	}
+	c, err := q.CountRows("Items")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if c != 0 {
+		t.Errorf("expected 0 got %d", c)
+	}
+
+	n, err = q.Truncate("Items")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if n != 0 {
+		t.Errorf("expected 0 got %d", n)
+	}
+}
+
+func TestQL_DeleteByIDs(t *testing.T) {
+	q := openMemory(t)
+	execTx(t, q.db, migration)
+	execTx(t, q.db, "INSERT INTO Items VALUES (1, 1, 1), (2, 2, 2), (3, 3, 3)")
+	rows, err := q.db.Query("SELECT id() FROM Items")
+	if err != nil {
+		t.Fatal(err)
+	}
+	var ids []int64
+	for rows.Next() {
+		var id int64
+		if err = rows.Scan(&id); err != nil {
+			t.Fatal(err)
+		}
+		ids = append(ids, id)
+	}
+	_ = rows.Close()
+
+	n, err := q.DeleteByIDs("Items", ids[0], ids[1], -1)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if n != 2 {
+		t.Errorf("expected 2 got %d", n)
+	}
+	c, err := q.CountRows("Items")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if c != 1 {
+		t.Errorf("expected 1 got %d", c)
+	}
+}
+
+func TestQL_HasRows(t *testing.T) {
+	q := openMemory(t)
+	execTx(t, q.db, migration)
+	ok, err := q.HasRows("Items")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if ok {
+		t.Error("expected to be false")
+	}
+	execTx(t, q.db, "INSERT INTO Items VALUES (1, 1, 1)")
+	ok, err = q.HasRows("Items")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !ok {
+		t.Error("expected to be true")
+	}
+	if _, err = q.HasRows("Missing"); err == nil {
+		t.Error("expected an error")
+	}
+}
+
+func TestQL_CountDistinct(t *testing.T) {
+	q := openMemory(t)
+	execTx(t, q.db, migration)
+	execTx(t, q.db, "INSERT INTO Items VALUES (1, 10, 1), (1, 11, 1), (2, 10, 2), (3, 12, NULL), (4, 13, NULL)")
+	for _, v := range []struct {
+		column string
+		count  int64
+	}{
+		{"OrderID", 4},
+		{"ProductID", 4},
+		{"Qty", 2},
+	} {
+		n, err := q.CountDistinct("Items", v.column)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if n != v.count {
+			t.Errorf("%s: expected %d got %d", v.column, v.count, n)
+		}
+	}
+	if _, err := q.CountDistinct("Items", "Price"); err == nil {
+		t.Error("expected an error for a missing column")
+	}
+}
+
+func TestQL_ColumnCardinality(t *testing.T) {
+	q := openMemory(t)
+	execTx(t, q.db, migration)
+	execTx(t, q.db, "INSERT INTO Items VALUES (1, 10, 1), (2, 11, 1), (3, 12, 1), (4, 13, 2), (5, 14, 2)")
+	for _, v := range []struct {
+		column   string
+		distinct int64
+	}{
+		{"ProductID", 5},
+		{"Qty", 2},
+	} {
+		distinct, total, err := q.ColumnCardinality("Items", v.column)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if distinct != v.distinct || total != 5 {
+			t.Errorf("%s: expected %d of 5 got %d of %d", v.column, v.distinct, distinct, total)
+		}
+	}
+	if _, _, err := q.ColumnCardinality("Items", "Price"); err == nil {
+		t.Error("expected an error for a missing column")
+	}
+}
+
+func TestQL_DeduplicateRows(t *testing.T) {
+	q := openMemory(t)
+	execTx(t, q.db, migration)
+	ids, err := q.BatchInsertReturningIDs(q.db, "Items", []string{"OrderID", "ProductID", "Qty"}, [][]interface{}{
+		{int64(1), int64(10), int64(1)},
+		{int64(1), int64(10), int64(2)},
+		{int64(1), int64(11), int64(3)},
+		{int64(2), int64(10), int64(4)},
+		{int64(1), int64(10), int64(5)},
+		{int64(2), int64(10), int64(6)},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	n, err := q.DeduplicateRows(q.db, "Items", []string{"OrderID", "ProductID"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if n != 3 {
+		t.Errorf("expected 3 got %d", n)
+	}
+	rows, err := q.db.Query("SELECT id() FROM Items ORDER BY id()")
+	if err != nil {
+		t.Fatal(err)
+	}
+	var left []int64
+	for rows.Next() {
+		var id int64
+		if err = rows.Scan(&id); err != nil {
+			t.Fatal(err)
+		}
+		left = append(left, id)
+	}
+	_ = rows.Close()
+	exp := []int64{ids[0], ids[2], ids[3]}
+	if !reflect.DeepEqual(left, exp) {
+		t.Errorf("expected %v got %v", exp, left)
+	}
+	n, err = q.DeduplicateRows(q.db, "Items", []string{"OrderID", "ProductID"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if n != 0 {
+		t.Errorf("expected 0 got %d", n)
+	}
+}
+
+func TestQL_HasIDGaps(t *testing.T) {
+	q := openMemory(t)
+	execTx(t, q.db, migration)
+	gaps, err := q.HasIDGaps("Items")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if gaps {
+		t.Error("expected no gaps in an empty table")
+	}
+	ids, err := q.BatchInsertReturningIDs(q.db, "Items", []string{"Qty"}, [][]interface{}{
+		{int64(1)}, {int64(2)}, {int64(3)},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	gaps, err = q.HasIDGaps("Items")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if gaps {
+		t.Error("expected no gaps")
+	}
+	if _, err = q.DeleteByIDs("Items", ids[1:2]...); err != nil {
+		t.Fatal(err)
+	}
+	gaps, err = q.HasIDGaps("Items")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !gaps {
+		t.Error("expected gaps after deleting a record")
+	}
+}
+
+func TestQL_NumericStats(t *testing.T) {
+	q := openMemory(t)
+	execTx(t, q.db, "CREATE TABLE readings (name string, value int, ratio bigrat)")
+	if _, _, _, err := q.NumericStats("readings", "value"); err != ErrNoValue {
+		t.Errorf("expected %v got %v", ErrNoValue, err)
+	}
+	execTx(t, q.db, `INSERT INTO readings VALUES ("a", 1, bigrat("1/2")), ("b", 4, bigrat("3/2")), ("c", 10, NULL), ("d", NULL, NULL)`)
+	min, max, avg, err := q.NumericStats("readings", "value")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if min != 1 || max != 10 || avg != 5 {
+		t.Errorf("expected 1, 10, 5 got %v, %v, %v", min, max, avg)
+	}
+	min, max, avg, err = q.NumericStats("readings", "ratio")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if min != 0.5 || max != 1.5 || avg != 1 {
+		t.Errorf("expected 0.5, 1.5, 1 got %v, %v, %v", min, max, avg)
+	}
+	if _, _, _, err = q.NumericStats("readings", "name"); err == nil {
+		t.Error("expected an error for a string column")
+	}
+	if _, _, _, err = q.NumericStats("readings", "missing"); err == nil {
+		t.Error("expected an error for a missing column")
+	}
+}
+
+func TestQL_SetRowLimit(t *testing.T) {
+	q := openMemory(t)
+	execTx(t, q.db, migration)
+	q.SetAutoTransaction(true)
+	q.SetRowLimit("Items", 2)
+	values := map[string]interface{}{"OrderID": int64(1), "Qty": int64(1)}
+	for i := 0; i < 2; i++ {
+		if _, err := q.ExecInsert(q.db, "Items", values); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if _, err := q.ExecInsert(q.db, "Items", values); err != ErrRowLimitReached {
+		t.Errorf("expected %v got %v", ErrRowLimitReached, err)
+	}
+	if err := q.BatchInsert(q.db, "Orders", []string{"CustomerID"}, [][]interface{}{{1}, {2}, {3}}); err != nil {
+		t.Errorf("expected other tables to have no limit got %v", err)
+	}
+	q.SetRowLimit("Orders", 4)
+	if err := q.BatchInsert(q.db, "Orders", []string{"CustomerID"}, [][]interface{}{{4}, {5}}); err != ErrRowLimitReached {
+		t.Errorf("expected %v got %v", ErrRowLimitReached, err)
+	}
+	if n, err := q.CountRows("Orders"); err != nil || n != 3 {
+		t.Errorf("expected the batch to be rejected as a whole got %d, %v", n, err)
+	}
+	q.SetRowLimit("Items", 0)
+	if _, err := q.ExecInsert(q.db, "Items", values); err != nil {
+		t.Errorf("expected the limit to be removed got %v", err)
+	}
+}
@@ -0,0 +1,52 @@
+package ql
+
+import (
+	"database/sql"
+	"errors"
+)
+
+// ErrNoValue is returned by ScalarInt64 and ScalarString when the query yields
+// no rows, and by NumericStats when there are no values.
+var ErrNoValue = errors.New("ql: query returned no rows")
+
+// ScalarInt64 runs query and returns the first column of the only expected
+// row as an int64.
+func (q *QL) ScalarInt64(query string, args ...interface{}) (int64, error) {
+	if q.db == nil {
+		return 0, ErrNoDB
+	}
+	return scalarInt64(q.conn(), query, args...)
+}
+
+// ScalarString runs query and returns the first column of the only expected
+// row as a string.
+func (q *QL) ScalarString(query string, args ...interface{}) (string, error) {
+	if q.db == nil {
+		return "", ErrNoDB
+	}
+	return scalarString(q.conn(), query, args...)
+}
+
+func scalarInt64(db queryer, query string, args ...interface{}) (int64, error) {
+	var v int64
+	if err := scalar(db, &v, query, args...); err != nil {
+		return 0, err
+	}
+	return v, nil
+}
+
+func scalarString(db queryer, query string, args ...interface{}) (string, error) {
+	var v string
+	if err := scalar(db, &v, query, args...); err != nil {
+		return "", err
+	}
+	return v, nil
+}
+
+func scalar(db queryer, dst interface{}, query string, args ...interface{}) error {
+	err := db.QueryRow(query, args...).Scan(dst)
+	if err == sql.ErrNoRows {
+		return ErrNoValue
+	}
+	return err
+}
@@ -0,0 +1,43 @@
+package ql
+
+import (
+	"testing"
+)
+
+func TestQL_ScalarInt64(t *testing.T) {
+	q := openMemory(t)
+	execTx(t, q.db, migration)
+	execTx(t, q.db, "INSERT INTO Items VALUES (1, 1, 1), (2, 2, 2)")
+	n, err := q.ScalarInt64("SELECT count() FROM Items")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if n != 2 {
+		t.Errorf("expected 2 got %d", n)
+	}
+	n, err = q.ScalarInt64("SELECT Qty FROM Items WHERE OrderID == $1", 2)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if n != 2 {
+		t.Errorf("expected 2 got %d", n)
+	}
+	if _, err = q.ScalarInt64("SELECT Qty FROM Items WHERE OrderID == $1", 3); err != ErrNoValue {
+		t.Errorf("expected %v got %v", ErrNoValue, err)
+	}
+}
+
+func TestQL_ScalarString(t *testing.T) {
+	q := openMemory(t)
+	execTx(t, q.db, `CREATE TABLE users (name string); INSERT INTO users VALUES ("gernest")`)
+	s, err := q.ScalarString("SELECT name FROM users")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if s != "gernest" {
+		t.Errorf("expected gernest got %s", s)
+	}
+	if _, err = q.ScalarString("SELECT name FROM users WHERE name == $1", "x"); err != ErrNoValue {
+		t.Errorf("expected %v got %v", ErrNoValue, err)
+	}
+}
@@ -0,0 +1,409 @@
+package ql
+
+import (
+	"database/sql"
+	"fmt"
+	"regexp"
+	"strings"
+)
+
+// queryer is the subset of model.SQLCommon that is shared with *sql.Tx, so the
+// introspection helpers work both on the dialect's handle and inside a
+// transaction.
+type queryer interface {
+	Exec(query string, args ...interface{}) (sql.Result, error)
+	Query(query string, args ...interface{}) (*sql.Rows, error)
+	QueryRow(query string, args ...interface{}) *sql.Row
+}
+
+// Column describes a table column.
+type Column struct {
+	Name string
+
+	// Type is the ql type of the column, as reported by ql. Aliases are
+	// resolved, a column declared as int is reported as int64.
+	Type string
+}
+
+// IndexColumn describes one indexed expression of an index. An index over
+// several columns is reported as one IndexColumn per column, in index order.
+type IndexColumn struct {
+	Index  string
+	Unique bool
+
+	// Column is the indexed column name, or id() for indexes over the record
+	// id.
+	Column string
+}
+
+// index describes an index as reported by the __Index2 and __Index2_Expr
+// system tables. Exprs holds the indexed expressions in index order, which for
+// simple indexes is a single column name or id().
+type index struct {
+	Name   string
+	Table  string
+	Unique bool
+	Exprs  []string
+}
+
+// qlTables are the meta data tables maintained by ql itself.
+var qlTables = map[string]bool{
+	"__Column":        true,
+	"__Column2":       true,
+	"__Index":         true,
+	"__Index2":        true,
+	"__Index2_Column": true,
+	"__Index2_Expr":   true,
+	"__Table":         true,
+}
+
+// isSystemTable returns true if name is reserved for meta data tables. Besides
+// the ones of ql this covers the side tables the dialect keeps, like the one
+// holding column comments.
+func isSystemTable(name string) bool {
+	return strings.HasPrefix(name, "__")
+}
+
+// ListTables returns the names of the user tables in the database ordered by
+// name. The meta data tables are not listed.
+func (q *QL) ListTables() ([]string, error) {
+	if q.db == nil {
+		return nil, ErrNoDB
+	}
+	all, err := tables(q.conn())
+	if err != nil {
+		return nil, err
+	}
+	var names []string
+	for _, name := range all {
+		if !isSystemTable(name) {
+			names = append(names, name)
+		}
+	}
+	return names, nil
+}
+
+// ListColumns returns the columns of tableName in the order they are stored
+// in the record. A table that does not exist has no columns.
+func (q *QL) ListColumns(tableName string) ([]Column, error) {
+	if q.db == nil {
+		return nil, ErrNoDB
+	}
+	return columns(q.conn(), tableName)
+}
+
+// ColumnType returns the ql type of the column columnName of tableName, as
+// reported by ListColumns.
+func (q *QL) ColumnType(tableName, columnName string) (string, error) {
+	cols, err := q.ListColumns(tableName)
+	if err != nil {
+		return "", err
+	}
+	for _, c := range cols {
+		if c.Name == columnName {
+			return c.Type, nil
+		}
+	}
+	return "", fmt.Errorf("ql: table %s has no column %s", tableName, columnName)
+}
+
+// ColumnsOfType returns the names of the columns of tableName whose type is
+// qlType, in record order. Aliases are resolved and case is ignored, so int
+// matches int64 columns.
+func (q *QL) ColumnsOfType(tableName, qlType string) ([]string, error) {
+	cols, err := q.ListColumns(tableName)
+	if err != nil {
+		return nil, err
+	}
+	typ := canonicalType(qlType)
+	var names []string
+	for _, c := range cols {
+		if canonicalType(c.Type) == typ {
+			names = append(names, c.Name)
+		}
+	}
+	return names, nil
+}
+
+// TablesMissingTimestamps returns the user tables, ordered by name, that lack
+// at least one of columns, for instance CreatedAt and UpdatedAt, to find the
+// tables that do not follow a timestamp convention.
+func (q *QL) TablesMissingTimestamps(columns []string) ([]string, error) {
+	names, err := q.ListTables()
+	if err != nil {
+		return nil, err
+	}
+	var missing []string
+	for _, name := range names {
+		has, err := q.HasColumns(name, columns)
+		if err != nil {
+			return nil, err
+		}
+		for _, c := range columns {
+			if !has[c] {
+				missing = append(missing, name)
+				break
+			}
+		}
+	}
+	return missing, nil
+}
+
+// ListIndexes returns the indexes defined on tableName ordered by index name,
+// with one entry per indexed column.
+func (q *QL) ListIndexes(tableName string) ([]IndexColumn, error) {
+	if q.db == nil {
+		return nil, ErrNoDB
+	}
+	idx, err := indexes(q.conn(), tableName)
+	if err != nil {
+		return nil, err
+	}
+	var o []IndexColumn
+	for _, i := range idx {
+		for _, e := range i.Exprs {
+			o = append(o, IndexColumn{Index: i.Name, Unique: i.Unique, Column: e})
+		}
+	}
+	return o, nil
+}
+
+// ListIndexesGrouped is like ListIndexes but maps each index name to its
+// columns, in the order they appear in the index.
+func (q *QL) ListIndexesGrouped(tableName string) (map[string][]string, error) {
+	if q.db == nil {
+		return nil, ErrNoDB
+	}
+	idx, err := indexes(q.conn(), tableName)
+	if err != nil {
+		return nil, err
+	}
+	o := make(map[string][]string, len(idx))
+	for _, i := range idx {
+		o[i.Name] = append([]string(nil), i.Exprs...)
+	}
+	return o, nil
+}
+
+// tables returns the names of all tables in the database ordered by name. The
+// side tables of the dialect are included but the ones of ql are not.
+func tables(db queryer) ([]string, error) {
+	rows, err := db.Query("select Name from __Table order by Name")
+	if err != nil {
+		return nil, err
+	}
+	defer func() {
+		_ = rows.Close()
+	}()
+	var names []string
+	for rows.Next() {
+		var name string
+		if err = rows.Scan(&name); err != nil {
+			return nil, err
+		}
+		if !qlTables[name] {
+			names = append(names, name)
+		}
+	}
+	return names, rows.Err()
+}
+
+// columns returns the columns of tableName in the order they are stored in
+// the record.
+func columns(db queryer, tableName string) ([]Column, error) {
+	query := "select Ordinal, Name, Type from __Column where TableName=$1 order by Ordinal"
+	rows, err := db.Query(query, tableName)
+	if err != nil {
+		return nil, err
+	}
+	defer func() {
+		_ = rows.Close()
+	}()
+	var cols []Column
+	for rows.Next() {
+		var ordinal int
+		var c Column
+		if err = rows.Scan(&ordinal, &c.Name, &c.Type); err != nil {
+			return nil, err
+		}
+		cols = append(cols, c)
+	}
+	return cols, rows.Err()
+}
+
+// indexes returns all indexes defined on tableName ordered by name. ql only
+// creates the __Index2 table once the database has an index.
+func indexes(db queryer, tableName string) ([]index, error) {
+	n, err := scalarInt64(db, "select count() from __Table where Name=$1", "__Index2")
+	if err != nil || n == 0 {
+		return nil, err
+	}
+	query := "select id(), IndexName, IsUnique from __Index2 where TableName=$1 order by IndexName"
+	rows, err := db.Query(query, tableName)
+	if err != nil {
+		return nil, err
+	}
+	var idx []index
+	var ids []int64
+	for rows.Next() {
+		var id int64
+		i := index{Table: tableName}
+		if err = rows.Scan(&id, &i.Name, &i.Unique); err != nil {
+			_ = rows.Close()
+			return nil, err
+		}
+		idx = append(idx, i)
+		ids = append(ids, id)
+	}
+	_ = rows.Close()
+	if err = rows.Err(); err != nil {
+		return nil, err
+	}
+	for k := range idx {
+		exprs, err := indexExprs(db, ids[k])
+		if err != nil {
+			return nil, err
+		}
+		idx[k].Exprs = exprs
+	}
+	return idx, nil
+}
+
+func indexExprs(db queryer, id int64) ([]string, error) {
+	query := "select id(), Expr from __Index2_Expr where Index2_ID=$1 order by id()"
+	rows, err := db.Query(query, id)
+	if err != nil {
+		return nil, err
+	}
+	defer func() {
+		_ = rows.Close()
+	}()
+	var exprs []string
+	for rows.Next() {
+		var id int64
+		var e string
+		if err = rows.Scan(&id, &e); err != nil {
+			return nil, err
+		}
+		exprs = append(exprs, e)
+	}
+	return exprs, rows.Err()
+}
+
+// tableSchema returns the CREATE TABLE statement ql keeps for tableName.
+func tableSchema(db queryer, tableName string) (string, error) {
+	var schema string
+	err := db.QueryRow("select Schema from __Table where Name=$1", tableName).Scan(&schema)
+	if err != nil {
+		return "", err
+	}
+	return schema, nil
+}
+
+// createIndexSQL returns the statement that recreates i.
+func createIndexSQL(i index) string {
+	unique := ""
+	if i.Unique {
+		unique = "UNIQUE "
+	}
+	return fmt.Sprintf("CREATE %sINDEX %s ON %s (%s)", unique, i.Name, i.Table, strings.Join(i.Exprs, ", "))
+}
+
+// FindOrphanedIndexes returns the names of the indexes of tableName that refer
+// to a column the table does not have, ordered by name. ql drops the indexes
+// of a column together with it, so these only show up in a damaged database.
+func (q *QL) FindOrphanedIndexes(tableName string) ([]string, error) {
+	if q.db == nil {
+		return nil, ErrNoDB
+	}
+	db := q.conn()
+	cols, err := columns(db, tableName)
+	if err != nil {
+		return nil, err
+	}
+	idx, err := indexes(db, tableName)
+	if err != nil {
+		return nil, err
+	}
+	return orphanedIndexes(cols, idx), nil
+}
+
+// VerifySchemaConsistency returns an error describing what is wrong with the
+// schema of tableName, or nil if nothing is: the table must exist and its
+// indexes must only refer to its columns.
+func (q *QL) VerifySchemaConsistency(tableName string) error {
+	if q.db == nil {
+		return ErrNoDB
+	}
+	db := q.conn()
+	cols, err := columns(db, tableName)
+	if err != nil {
+		return err
+	}
+	idx, err := indexes(db, tableName)
+	if err != nil {
+		return err
+	}
+	return verifySchema(tableName, cols, idx)
+}
+
+func verifySchema(tableName string, cols []Column, idx []index) error {
+	if len(cols) == 0 {
+		return fmt.Errorf("ql: table %s does not exist", tableName)
+	}
+	var problems []string
+	for _, i := range idx {
+		for _, name := range missingColumns(cols, i) {
+			problems = append(problems, fmt.Sprintf("index %s refers to missing column %s", i.Name, name))
+		}
+	}
+	if len(problems) > 0 {
+		return fmt.Errorf("ql: table %s is inconsistent: %s", tableName, strings.Join(problems, ", "))
+	}
+	return nil
+}
+
+func orphanedIndexes(cols []Column, idx []index) []string {
+	var names []string
+	for _, i := range idx {
+		if len(missingColumns(cols, i)) > 0 {
+			names = append(names, i.Name)
+		}
+	}
+	return names
+}
+
+// wordRe matches the identifiers and numbers of an indexed expression. The
+// identifiers followed by an opening parenthesis are function names.
+var wordRe = regexp.MustCompile(`[A-Za-z0-9_.]+\s*\(?`)
+
+// missingColumns returns the columns the expressions of i refer to that are not
+// in cols. String literals in the expressions are skipped.
+func missingColumns(cols []Column, i index) []string {
+	known := make(map[string]bool)
+	for _, c := range cols {
+		known[c.Name] = true
+	}
+	var missing []string
+	for _, e := range i.Exprs {
+		var b strings.Builder
+		for k := 0; k < len(e); k++ {
+			switch e[k] {
+			case '"', '`', '\'':
+				k = literalEnd(e, k) - 1
+				b.WriteByte(' ')
+			default:
+				b.WriteByte(e[k])
+			}
+		}
+		for _, name := range wordRe.FindAllString(b.String(), -1) {
+			name = strings.TrimSpace(name)
+			if strings.HasSuffix(name, "(") || name[0] >= '0' && name[0] <= '9' ||
+				known[name] || isReservedWord(name) {
+				continue
+			}
+			missing = append(missing, name)
+		}
+	}
+	return missing
+}
@@ -0,0 +1,200 @@
+package ql
+
+import (
+	"reflect"
+	"testing"
+)
+
+func TestQL_ListTables(t *testing.T) {
+	q := openMemory(t)
+	execTx(t, q.db, migration)
+	if err := q.SetColumnComment("Orders", "Date", "placed at"); err != nil {
+		t.Fatal(err)
+	}
+	o, err := q.ListTables()
+	if err != nil {
+		t.Fatal(err)
+	}
+	exp := []string{"Items", "Orders"}
+	if !reflect.DeepEqual(o, exp) {
+		t.Errorf("expected %v got %v", exp, o)
+	}
+}
+
+func TestQL_ListColumns(t *testing.T) {
+	q := openMemory(t)
+	execTx(t, q.db, migration)
+	o, err := q.ListColumns("Orders")
+	if err != nil {
+		t.Fatal(err)
+	}
+	exp := []Column{
+		{Name: "CustomerID", Type: "int64"},
+		{Name: "Date", Type: "time"},
+	}
+	if !reflect.DeepEqual(o, exp) {
+		t.Errorf("expected %v got %v", exp, o)
+	}
+	o, err = q.ListColumns("Missing")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(o) != 0 {
+		t.Errorf("expected no columns got %v", o)
+	}
+}
+
+func TestQL_ColumnType(t *testing.T) {
+	q := openMemory(t)
+	execTx(t, q.db, migration)
+	typ, err := q.ColumnType("Orders", "Date")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if typ != "time" {
+		t.Errorf("expected time got %s", typ)
+	}
+	if _, err = q.ColumnType("Orders", "Missing"); err == nil {
+		t.Error("expected an error")
+	}
+}
+
+func TestQL_ColumnsOfType(t *testing.T) {
+	q := openMemory(t)
+	execTx(t, q.db, "CREATE TABLE files (name string, data blob, size int, thumb blob)")
+	o, err := q.ColumnsOfType("files", "blob")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if exp := []string{"data", "thumb"}; !reflect.DeepEqual(o, exp) {
+		t.Errorf("expected %v got %v", exp, o)
+	}
+	o, err = q.ColumnsOfType("files", "INT")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if exp := []string{"size"}; !reflect.DeepEqual(o, exp) {
+		t.Errorf("expected %v got %v", exp, o)
+	}
+	o, err = q.ColumnsOfType("files", "time")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(o) != 0 {
+		t.Errorf("expected no columns got %v", o)
+	}
+}
+
+func TestQL_TablesMissingTimestamps(t *testing.T) {
+	q := openMemory(t)
+	execTx(t, q.db, `
+BEGIN TRANSACTION;
+	CREATE TABLE Users (Name string, CreatedAt time, UpdatedAt time);
+	CREATE TABLE Posts (Title string, CreatedAt time);
+	CREATE TABLE Tags (Name string);
+COMMIT;
+`)
+	o, err := q.TablesMissingTimestamps([]string{"CreatedAt", "UpdatedAt"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	exp := []string{"Posts", "Tags"}
+	if !reflect.DeepEqual(o, exp) {
+		t.Errorf("expected %v got %v", exp, o)
+	}
+	if _, err := Memory().TablesMissingTimestamps([]string{"CreatedAt"}); err != ErrNoDB {
+		t.Errorf("expected %v got %v", ErrNoDB, err)
+	}
+}
+
+func TestQL_ListIndexes(t *testing.T) {
+	q := openMemory(t)
+	execTx(t, q.db, migration)
+	execTx(t, q.db, "CREATE UNIQUE INDEX ItemsProduct ON Items (OrderID, ProductID)")
+	o, err := q.ListIndexes("Items")
+	if err != nil {
+		t.Fatal(err)
+	}
+	exp := []IndexColumn{
+		{Index: "ItemsOrderID", Column: "OrderID"},
+		{Index: "ItemsProduct", Unique: true, Column: "OrderID"},
+		{Index: "ItemsProduct", Unique: true, Column: "ProductID"},
+	}
+	if !reflect.DeepEqual(o, exp) {
+		t.Errorf("expected %v got %v", exp, o)
+	}
+	o, err = q.ListIndexes("Orders")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(o) != 2 || o[1].Column != "id()" {
+		t.Errorf("expected the id() index got %v", o)
+	}
+}
+
+func TestQL_ListIndexesGrouped(t *testing.T) {
+	q := openMemory(t)
+	execTx(t, q.db, migration)
+	execTx(t, q.db, "CREATE INDEX OrdersCustomerDate ON Orders (CustomerID, Date)")
+	o, err := q.ListIndexesGrouped("Orders")
+	if err != nil {
+		t.Fatal(err)
+	}
+	exp := []string{"CustomerID", "Date"}
+	if !reflect.DeepEqual(o["OrdersCustomerDate"], exp) {
+		t.Errorf("expected %v got %v", exp, o)
+	}
+	if len(o) != 3 {
+		t.Errorf("expected 3 indexes got %v", o)
+	}
+}
+
+func TestQL_VerifySchemaConsistency(t *testing.T) {
+	q := openMemory(t)
+	execTx(t, q.db, migration)
+	execTx(t, q.db, "CREATE INDEX OrdersCustomerDate ON Orders (CustomerID, Date)")
+	if err := q.VerifySchemaConsistency("Orders"); err != nil {
+		t.Errorf("expected a consistent table got %v", err)
+	}
+	o, err := q.FindOrphanedIndexes("Orders")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(o) != 0 {
+		t.Errorf("expected no orphaned indexes got %v", o)
+	}
+	// ql drops the indexes of a dropped column.
+	execTx(t, q.db, "ALTER TABLE Orders DROP COLUMN Date")
+	if err = q.VerifySchemaConsistency("Orders"); err != nil {
+		t.Errorf("expected a consistent table got %v", err)
+	}
+	if err = q.VerifySchemaConsistency("Missing"); err == nil {
+		t.Error("expected an error for a missing table")
+	}
+}
+
+func TestVerifySchema(t *testing.T) {
+	// ql itself never leaves an index behind, so the damaged schema is made
+	// up.
+	cols := []Column{{Name: "CustomerID", Type: "int64"}, {Name: "Total", Type: "float64"}}
+	idx := []index{
+		{Name: "OrdersID", Table: "Orders", Exprs: []string{"id()"}},
+		{Name: "OrdersCustomerDate", Table: "Orders", Exprs: []string{"CustomerID", "Date"}},
+		{Name: "OrdersTotal", Table: "Orders", Exprs: []string{`float64(Total) * 1e3 + len("Tax")`}},
+		{Name: "OrdersTax", Table: "Orders", Exprs: []string{"Total + Tax"}},
+	}
+	if o, exp := orphanedIndexes(cols, idx), []string{"OrdersCustomerDate", "OrdersTax"}; !reflect.DeepEqual(o, exp) {
+		t.Errorf("expected %v got %v", exp, o)
+	}
+	err := verifySchema("Orders", cols, idx)
+	if err == nil {
+		t.Fatal("expected an error")
+	}
+	exp := "ql: table Orders is inconsistent: index OrdersCustomerDate refers to missing column Date, index OrdersTax refers to missing column Tax"
+	if err.Error() != exp {
+		t.Errorf("expected %s got %v", exp, err)
+	}
+	if err = verifySchema("Orders", cols, idx[:1]); err != nil {
+		t.Errorf("expected no error got %v", err)
+	}
+}
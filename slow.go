@@ -0,0 +1,68 @@
+package ql
+
+import (
+	"database/sql"
+	"time"
+)
+
+// SetSlowQueryThreshold makes the dialect time the statements it runs itself,
+// like the introspection queries of HasTable or the maintenance statements of
+// Truncate, and report the ones that take longer than d to the handler set with
+// SetSlowQueryHandler. Zero, the default, disables timing.
+func (q *QL) SetSlowQueryThreshold(d time.Duration) {
+	q.slowThreshold = d
+}
+
+// SetSlowQueryHandler sets the function called with the statement and its
+// duration when an internal statement exceeds the slow query threshold.
+func (q *QL) SetSlowQueryHandler(fn func(query string, elapsed time.Duration)) {
+	q.slowHandler = fn
+}
+
+// conn returns the dialect's handle for running internal statements.
+func (q *QL) conn() queryer {
+	return q.observed(q.db)
+}
+
+// observed wraps db so its statements are timed when a slow query threshold
+// and handler are set and recorded when a statement recorder is set, otherwise
+// db is returned as is.
+func (q *QL) observed(db queryer) queryer {
+	timed := q.slowThreshold > 0 && q.slowHandler != nil
+	if !timed && q.recorder == nil {
+		return db
+	}
+	return &observedQueryer{db: db, q: q}
+}
+
+type observedQueryer struct {
+	db queryer
+	q  *QL
+}
+
+func (o *observedQueryer) observe(query string, args []interface{}, start time.Time) {
+	if o.q.recorder != nil {
+		o.q.recorder.record(query, args)
+	}
+	if o.q.slowThreshold <= 0 || o.q.slowHandler == nil {
+		return
+	}
+	if elapsed := time.Since(start); elapsed >= o.q.slowThreshold {
+		o.q.slowHandler(query, elapsed)
+	}
+}
+
+func (o *observedQueryer) Exec(query string, args ...interface{}) (sql.Result, error) {
+	defer o.observe(query, args, time.Now())
+	return o.db.Exec(query, args...)
+}
+
+func (o *observedQueryer) Query(query string, args ...interface{}) (*sql.Rows, error) {
+	defer o.observe(query, args, time.Now())
+	return o.db.Query(query, args...)
+}
+
+func (o *observedQueryer) QueryRow(query string, args ...interface{}) *sql.Row {
+	defer o.observe(query, args, time.Now())
+	return o.db.QueryRow(query, args...)
+}
@@ -0,0 +1,55 @@
+package ql
+
+import (
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestQL_SetSlowQueryThreshold(t *testing.T) {
+	q := openMemory(t)
+	execTx(t, q.db, migration)
+	var queries []string
+	q.SetSlowQueryHandler(func(query string, elapsed time.Duration) {
+		if elapsed <= 0 {
+			t.Errorf("expected positive duration got %v", elapsed)
+		}
+		queries = append(queries, query)
+	})
+
+	// timing is disabled by default
+	q.HasTable("Orders")
+	if len(queries) != 0 {
+		t.Errorf("expected no slow queries got %v", queries)
+	}
+
+	q.SetSlowQueryThreshold(time.Nanosecond)
+	if !q.HasTable("Orders") {
+		t.Error("expected to be true")
+	}
+	if len(queries) != 1 || !strings.Contains(queries[0], "__Table") {
+		t.Fatalf("expected the HasTable query got %v", queries)
+	}
+
+	// statements run inside the dialect's transactions are timed too
+	queries = nil
+	if _, err := q.Truncate("Items"); err != nil {
+		t.Fatal(err)
+	}
+	var truncate bool
+	for _, v := range queries {
+		if strings.HasPrefix(v, "TRUNCATE TABLE") {
+			truncate = true
+		}
+	}
+	if !truncate {
+		t.Errorf("expected the TRUNCATE TABLE statement got %v", queries)
+	}
+
+	q.SetSlowQueryThreshold(0)
+	queries = nil
+	q.HasTable("Orders")
+	if len(queries) != 0 {
+		t.Errorf("expected no slow queries got %v", queries)
+	}
+}
@@ -0,0 +1,56 @@
+package ql
+
+import (
+	"fmt"
+	"time"
+
+	"github.com/akamajoris/ngorm/model"
+)
+
+// SoftDeleteColumn returns the column of fields that marks records as deleted.
+// That is the column of the field tagged soft_delete, or lacking one of the
+// DeletedAt field, like ngorm does.
+func (q *QL) SoftDeleteColumn(fields []*model.StructField) (string, bool) {
+	var deletedAt *model.StructField
+	for _, f := range fields {
+		if !f.IsNormal || f.IsIgnored {
+			continue
+		}
+		if _, ok := f.TagSettings["SOFT_DELETE"]; ok {
+			return q.ColumnName(f), true
+		}
+		if f.Name == "DeletedAt" {
+			deletedAt = f
+		}
+	}
+	if deletedAt != nil {
+		return q.ColumnName(deletedAt), true
+	}
+	return "", false
+}
+
+// SoftDeleteFilter returns the condition that excludes soft deleted records,
+// to be combined with the other conditions of a WHERE clause. It takes no
+// arguments, startIndex is returned as the next index so it composes like
+// CaseInsensitiveEq.
+func (q *QL) SoftDeleteFilter(column string, startIndex int) (sql string, args []interface{}, nextIndex int) {
+	return q.Quote(column) + " IS NULL", nil, startIndex
+}
+
+// SoftDelete marks the record of tableName whose id() is id as deleted by
+// setting column to the current time, the record stays in the table. The
+// statement runs in a transaction on tx, unless tx already is one.
+func (q *QL) SoftDelete(tx model.SQLCommon, tableName, column string, id int64) error {
+	query := fmt.Sprintf("UPDATE %s SET %s = %s WHERE id() == %s",
+		q.Quote(tableName), q.Quote(column), q.BindVar(1), q.BindVar(2))
+	return q.transactionOn(tx, func(tx queryer) error {
+		res, err := tx.Exec(query, time.Now(), id)
+		if err != nil {
+			return err
+		}
+		if n, err := res.RowsAffected(); err == nil && n == 0 {
+			return fmt.Errorf("ql: no record with id() %d in %s", id, tableName)
+		}
+		return nil
+	})
+}
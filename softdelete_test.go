@@ -0,0 +1,76 @@
+package ql
+
+import (
+	"testing"
+	"time"
+)
+
+type Note struct {
+	ID        int64
+	Text      string
+	DeletedAt *time.Time
+}
+
+type Draft struct {
+	ID        int64
+	Text      string
+	RemovedAt *time.Time `sql:"soft_delete"`
+	DeletedAt *time.Time
+}
+
+func TestQL_SoftDeleteColumn(t *testing.T) {
+	q := Memory()
+	c, ok := q.SoftDeleteColumn(modelFields(t, &Note{}))
+	if !ok || c != "deleted_at" {
+		t.Errorf("expected deleted_at got %q", c)
+	}
+	c, ok = q.SoftDeleteColumn(modelFields(t, &Draft{}))
+	if !ok || c != "removed_at" {
+		t.Errorf("expected removed_at got %q", c)
+	}
+	if _, ok = q.SoftDeleteColumn(modelFields(t, &Author{})); ok {
+		t.Error("expected no soft delete column")
+	}
+}
+
+func TestQL_SoftDelete(t *testing.T) {
+	q := openMemory(t)
+	execTx(t, q.db, "CREATE TABLE notes (text string, deleted_at time)")
+	ids, err := q.BatchInsertReturningIDs(q.db, "notes", []string{"text"}, [][]interface{}{{"keep"}, {"drop"}})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err = q.SoftDelete(q.db, "notes", "deleted_at", ids[1]); err != nil {
+		t.Fatal(err)
+	}
+	filter, args, next := q.SoftDeleteFilter("deleted_at", 1)
+	if filter != "deleted_at IS NULL" || len(args) != 0 || next != 1 {
+		t.Errorf("unexpected filter %s %v %d", filter, args, next)
+	}
+	rows, err := q.db.Query("SELECT text FROM notes WHERE " + filter)
+	if err != nil {
+		t.Fatal(err)
+	}
+	var texts []string
+	for rows.Next() {
+		var s string
+		if err = rows.Scan(&s); err != nil {
+			t.Fatal(err)
+		}
+		texts = append(texts, s)
+	}
+	_ = rows.Close()
+	if len(texts) != 1 || texts[0] != "keep" {
+		t.Errorf("expected [keep] got %v", texts)
+	}
+	n, err := q.CountRows("notes")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if n != 2 {
+		t.Errorf("expected the record to still exist got %d records", n)
+	}
+	if err = q.SoftDelete(q.db, "notes", "deleted_at", -1); err == nil {
+		t.Error("expected an error for a missing record")
+	}
+}
@@ -0,0 +1,173 @@
+package ql
+
+import (
+	"strings"
+	"unicode"
+)
+
+// IsReadOnlyStatement returns true if sql only reads from the database, which
+// is the case for SELECT and EXPLAIN statements. It can be used to route
+// queries between a read only copy of the database and the primary one.
+//
+// Leading white space and comments are ignored. A statement list, like one
+// wrapped in BEGIN TRANSACTION and COMMIT, is read only when every statement
+// in it is read only.
+func IsReadOnlyStatement(sql string) bool {
+	found := false
+	for _, stmt := range splitStatements(sql) {
+		switch leadingKeyword(stmt) {
+		case "":
+		case "BEGIN", "COMMIT", "ROLLBACK":
+		case "SELECT", "EXPLAIN":
+			found = true
+		default:
+			return false
+		}
+	}
+	return found
+}
+
+// leadingKeyword returns the first word of stmt in upper case.
+func leadingKeyword(stmt string) string {
+	stmt = strings.TrimSpace(stmt)
+	end := strings.IndexFunc(stmt, func(r rune) bool {
+		return !unicode.IsLetter(r)
+	})
+	if end == -1 {
+		end = len(stmt)
+	}
+	return strings.ToUpper(stmt[:end])
+}
+
+// splitStatements splits a statement list on semicolons and strips the
+// comments. Semicolons and comment markers inside string literals are left
+// alone.
+func splitStatements(sql string) []string {
+	var stmts []string
+	var b strings.Builder
+	for i := 0; i < len(sql); i++ {
+		c := sql[i]
+		switch {
+		case c == '"' || c == '`' || c == '\'':
+			end := literalEnd(sql, i)
+			b.WriteString(sql[i:end])
+			i = end - 1
+		case c == '-' && strings.HasPrefix(sql[i:], "--"),
+			c == '/' && strings.HasPrefix(sql[i:], "//"):
+			end := strings.IndexByte(sql[i:], '\n')
+			if end == -1 {
+				i = len(sql)
+			} else {
+				i += end
+			}
+			b.WriteByte(' ')
+		case c == '/' && strings.HasPrefix(sql[i:], "/*"):
+			end := strings.Index(sql[i+2:], "*/")
+			if end == -1 {
+				i = len(sql)
+			} else {
+				i += end + 3
+			}
+			b.WriteByte(' ')
+		case c == ';':
+			stmts = append(stmts, b.String())
+			b.Reset()
+		default:
+			b.WriteByte(c)
+		}
+	}
+	return append(stmts, b.String())
+}
+
+// TranslatePlaceholders rewrites the ? placeholders of sql, as used by other
+// databases and query builders, into numbered ones, so the first ? becomes $1,
+// the second $2 and so on. Question marks inside string literals are left
+// alone.
+func (q *QL) TranslatePlaceholders(sql string) string {
+	var b strings.Builder
+	n := 0
+	for i := 0; i < len(sql); i++ {
+		switch c := sql[i]; c {
+		case '"', '`', '\'':
+			end := literalEnd(sql, i)
+			b.WriteString(sql[i:end])
+			i = end - 1
+		case '?':
+			n++
+			b.WriteString(q.BindVar(n))
+		default:
+			b.WriteByte(c)
+		}
+	}
+	return b.String()
+}
+
+// NormalizeConditionSQL rewrites the operators of standard SQL in the WHERE
+// clause fragment sql into the ones ql documents: AND and OR become && and ||,
+// = becomes == and <> becomes !=. The AND of a BETWEEN is part of its syntax
+// and is kept. String literals are left alone.
+func NormalizeConditionSQL(sql string) string {
+	var b strings.Builder
+	between := false
+	for i := 0; i < len(sql); i++ {
+		c := sql[i]
+		switch {
+		case c == '"' || c == '`' || c == '\'':
+			end := literalEnd(sql, i)
+			b.WriteString(sql[i:end])
+			i = end - 1
+		case isWordByte(c):
+			end := i + 1
+			for end < len(sql) && isWordByte(sql[end]) {
+				end++
+			}
+			word := sql[i:end]
+			switch strings.ToUpper(word) {
+			case "BETWEEN":
+				between = true
+			case "AND":
+				if between {
+					between = false
+				} else {
+					word = "&&"
+				}
+			case "OR":
+				word = "||"
+			}
+			b.WriteString(word)
+			i = end - 1
+		case c == '<' && strings.HasPrefix(sql[i:], "<>"):
+			b.WriteString("!=")
+			i++
+		case c == '=' && strings.HasPrefix(sql[i:], "=="):
+			b.WriteString("==")
+			i++
+		case c == '=' && (i == 0 || strings.IndexByte("<>!", sql[i-1]) == -1):
+			b.WriteString("==")
+		default:
+			b.WriteByte(c)
+		}
+	}
+	return b.String()
+}
+
+func isWordByte(c byte) bool {
+	return c == '_' || c >= 'a' && c <= 'z' || c >= 'A' && c <= 'Z' || c >= '0' && c <= '9'
+}
+
+// literalEnd returns the index just past the string literal starting at
+// sql[start]. Interpreted literals may contain backslash escapes.
+func literalEnd(sql string, start int) int {
+	quote := sql[start]
+	for i := start + 1; i < len(sql); i++ {
+		switch sql[i] {
+		case '\\':
+			if quote != '`' {
+				i++
+			}
+		case quote:
+			return i + 1
+		}
+	}
+	return len(sql)
+}
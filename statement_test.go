@@ -0,0 +1,67 @@
+package ql
+
+import "testing"
+
+func TestIsReadOnlyStatement(t *testing.T) {
+	sample := []struct {
+		sql      string
+		readOnly bool
+	}{
+		{"SELECT * FROM Orders", true},
+		{"select count() from __Table", true},
+		{"EXPLAIN SELECT * FROM Orders", true},
+		{"INSERT INTO Orders VALUES (1, now())", false},
+		{"UPDATE Orders SET CustomerID = 2", false},
+		{"DELETE FROM Orders", false},
+		{"CREATE TABLE t (a int)", false},
+		{"  \n\t// all orders\n  /* of the day */ -- really\n SELECT * FROM Orders", true},
+		{"BEGIN TRANSACTION; SELECT * FROM Orders; COMMIT;", true},
+		{"BEGIN TRANSACTION; SELECT * FROM Orders; DELETE FROM Orders; COMMIT;", false},
+		{`SELECT "; DELETE FROM Orders" FROM Orders`, true},
+		{"/* SELECT */ DROP TABLE Orders", false},
+		{"", false},
+		{"BEGIN TRANSACTION; COMMIT;", false},
+	}
+	for _, v := range sample {
+		o := IsReadOnlyStatement(v.sql)
+		if o != v.readOnly {
+			t.Errorf("%q: expected %v got %v", v.sql, v.readOnly, o)
+		}
+	}
+}
+
+func TestQL_TranslatePlaceholders(t *testing.T) {
+	q := Memory()
+	sample := []struct {
+		sql, exp string
+	}{
+		{"SELECT * FROM t WHERE a = ? AND b = ?", "SELECT * FROM t WHERE a = $1 AND b = $2"},
+		{`SELECT * FROM t WHERE a = "why?" && b == ?`, `SELECT * FROM t WHERE a = "why?" && b == $1`},
+		{"SELECT * FROM t WHERE a LIKE `\\d?` && b IN (?, ?)", "SELECT * FROM t WHERE a LIKE `\\d?` && b IN ($1, $2)"},
+		{`SELECT "a \"?\" b", ?`, `SELECT "a \"?\" b", $1`},
+		{"SELECT 1", "SELECT 1"},
+	}
+	for _, v := range sample {
+		if o := q.TranslatePlaceholders(v.sql); o != v.exp {
+			t.Errorf("%s: expected %s got %s", v.sql, v.exp, o)
+		}
+	}
+}
+
+func TestNormalizeConditionSQL(t *testing.T) {
+	sample := []struct {
+		sql, exp string
+	}{
+		{"a = 1 AND b = 2", "a == 1 && b == 2"},
+		{"a == 1 or b <> 2", "a == 1 || b != 2"},
+		{"a <= 1 AND b >= 2 AND c != 3", "a <= 1 && b >= 2 && c != 3"},
+		{`name = "this AND that" AND x = 1`, `name == "this AND that" && x == 1`},
+		{"a BETWEEN 1 AND 5 AND b = 2", "a BETWEEN 1 AND 5 && b == 2"},
+		{"brand = $1 OR android = $2", "brand == $1 || android == $2"},
+	}
+	for _, v := range sample {
+		if o := NormalizeConditionSQL(v.sql); o != v.exp {
+			t.Errorf("%s: expected %s got %s", v.sql, v.exp, o)
+		}
+	}
+}
@@ -0,0 +1,250 @@
+package ql
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+
+	"github.com/akamajoris/ngorm/model"
+)
+
+// ErrNestedTransaction is returned by Tx.Begin, a transaction handle can not
+// start another transaction.
+var ErrNestedTransaction = errors.New("ql: already in a transaction")
+
+// Tx wraps a transaction so it can be used where a model.SQLCommon is expected,
+// for instance as the handle of the dialect. Helpers that need a transaction
+// run their statements in it instead of starting a new one.
+type Tx struct {
+	*sql.Tx
+}
+
+// Begin implements model.SQLCommon, it always fails with ErrNestedTransaction.
+func (t *Tx) Begin() (*sql.Tx, error) {
+	return nil, ErrNestedTransaction
+}
+
+// Close implements model.SQLCommon. It does nothing, the transaction is ended
+// with Commit or Rollback.
+func (t *Tx) Close() error {
+	return nil
+}
+
+// InTransaction returns true if db is a transaction rather than a connection to
+// the database. This is a best effort check, besides Tx any handle that can be
+// committed and rolled back is considered a transaction.
+func InTransaction(db model.SQLCommon) bool {
+	switch db.(type) {
+	case *Tx:
+		return true
+	case interface {
+		Commit() error
+		Rollback() error
+	}:
+		return true
+	}
+	return false
+}
+
+// RunInTransaction calls fn with a transaction, which is committed when fn
+// returns nil and rolled back otherwise. When the handle of the dialect is
+// already a transaction fn runs in it and committing is left to its owner.
+func (q *QL) RunInTransaction(fn func(tx model.SQLCommon) error) error {
+	if q.db == nil {
+		return ErrNoDB
+	}
+	if InTransaction(q.db) {
+		return fn(q.db)
+	}
+	return q.sqlTransaction(func(tx *sql.Tx) error {
+		return fn(&Tx{Tx: tx})
+	})
+}
+
+// transaction runs fn inside a transaction on the dialect's handle, the
+// statements fn runs through tx are observed like all internal statements.
+// When the handle already is a transaction, see InTransaction, fn runs in it
+// and committing is left to its owner.
+func (q *QL) transaction(fn func(tx queryer) error) error {
+	if q.db == nil {
+		return ErrNoDB
+	}
+	if InTransaction(q.db) {
+		return fn(q.observed(q.db))
+	}
+	return q.sqlTransaction(func(tx *sql.Tx) error {
+		return fn(q.observed(tx))
+	})
+}
+
+// sqlTransaction runs fn inside a new transaction on the dialect's handle. The
+// transaction is committed when fn returns nil and rolled back otherwise, and
+// the whole transaction is retried according to the retry policy. A handle
+// that already is a transaction can not start another one, callers run fn in
+// it instead.
+func (q *QL) sqlTransaction(fn func(tx *sql.Tx) error) error {
+	if q.db == nil {
+		return ErrNoDB
+	}
+	if InTransaction(q.db) {
+		return ErrNestedTransaction
+	}
+	return q.withRetry(func() error {
+		return q.runTransaction(fn)
+	})
+}
+
+func (q *QL) runTransaction(fn func(tx *sql.Tx) error) error {
+	tx, err := q.begin(q.db)
+	if err != nil {
+		return err
+	}
+	if err = fn(tx); err != nil {
+		q.rollback(tx)
+		return err
+	}
+	return q.commit(tx)
+}
+
+// SetTxHooks sets functions called when the dialect begins, commits and rolls
+// back a transaction, in RunInTransaction, RunInTransactionContext and the
+// helpers that write to the database, to count or time transactions for
+// instance. A commit that fails counts as a rollback. Transactions started by
+// the caller are not reported and any of the functions can be nil.
+func (q *QL) SetTxHooks(onBegin, onCommit, onRollback func()) {
+	q.onBegin, q.onCommit, q.onRollback = onBegin, onCommit, onRollback
+}
+
+// begin starts a transaction on db and reports it to the begin hook.
+func (q *QL) begin(db model.SQLCommon) (*sql.Tx, error) {
+	tx, err := db.Begin()
+	if err != nil {
+		return nil, err
+	}
+	if q.onBegin != nil {
+		q.onBegin()
+	}
+	return tx, nil
+}
+
+// commit commits tx and reports it to the commit or rollback hook.
+func (q *QL) commit(tx *sql.Tx) error {
+	err := tx.Commit()
+	switch {
+	case err == nil && q.onCommit != nil:
+		q.onCommit()
+	case err != nil && q.onRollback != nil:
+		q.onRollback()
+	}
+	return err
+}
+
+// rollback rolls tx back and reports it to the rollback hook.
+func (q *QL) rollback(tx *sql.Tx) {
+	_ = tx.Rollback()
+	if q.onRollback != nil {
+		q.onRollback()
+	}
+}
+
+// SetAutoTransaction makes the helpers that execute a single write, like
+// ExecInsert and ExecUpdate, wrap the statement in a transaction when the
+// handle they are given is not one already. ql only accepts writes inside a
+// transaction, without this option the caller has to start it.
+func (q *QL) SetAutoTransaction(ok bool) {
+	q.autoTransaction = ok
+}
+
+// execWrite executes the write statement query on db, in a transaction of its
+// own when auto transactions are enabled and db is not a transaction.
+func (q *QL) execWrite(db model.SQLCommon, query string, args ...interface{}) (sql.Result, error) {
+	if !q.autoTransaction || InTransaction(db) {
+		return q.observed(db).Exec(query, args...)
+	}
+	var res sql.Result
+	err := q.transactionOn(db, func(tx queryer) error {
+		var err error
+		res, err = tx.Exec(query, args...)
+		return err
+	})
+	if err != nil {
+		return nil, err
+	}
+	return res, nil
+}
+
+// transactionOn runs fn inside a transaction on db, which unlike the handle of
+// the dialect is given by the caller. When db already is a transaction fn runs
+// in it and committing is left to its owner.
+func (q *QL) transactionOn(db model.SQLCommon, fn func(tx queryer) error) error {
+	if InTransaction(db) {
+		return fn(q.observed(db))
+	}
+	tx, err := q.begin(db)
+	if err != nil {
+		return err
+	}
+	if err = fn(q.observed(tx)); err != nil {
+		q.rollback(tx)
+		return err
+	}
+	return q.commit(tx)
+}
+
+// RunInTransactionContext works like RunInTransaction but gives up when ctx is
+// done. If ctx expires before the transaction is committed, including while fn
+// is still running, the transaction is rolled back and ctx.Err() is returned.
+// fn itself is not interrupted, it should watch ctx if it runs for long.
+// When the handle is already a transaction the context is checked after fn
+// returns, rolling back is then left to the owner of the transaction.
+func (q *QL) RunInTransactionContext(ctx context.Context, fn func(tx model.SQLCommon) error) error {
+	if q.db == nil {
+		return ErrNoDB
+	}
+	if err := ctx.Err(); err != nil {
+		return err
+	}
+	if InTransaction(q.db) {
+		if err := fn(q.db); err != nil {
+			return err
+		}
+		return ctx.Err()
+	}
+	// The transaction is not started with BeginTx, database/sql discards the
+	// connection of a transaction whose context is done, which for the memory
+	// database loses all of its data.
+	tx, err := q.begin(q.db)
+	if err != nil {
+		return err
+	}
+	if err = fn(&Tx{Tx: tx}); err != nil {
+		q.rollback(tx)
+		if cerr := ctx.Err(); cerr != nil {
+			return cerr
+		}
+		return err
+	}
+	if err = ctx.Err(); err != nil {
+		q.rollback(tx)
+		return err
+	}
+	return q.commit(tx)
+}
+
+// contextHandle is implemented by the handles that accept a context, like
+// *sql.DB and *sql.Tx.
+type contextHandle interface {
+	ExecContext(ctx context.Context, query string, args ...interface{}) (sql.Result, error)
+	QueryContext(ctx context.Context, query string, args ...interface{}) (*sql.Rows, error)
+	QueryRowContext(ctx context.Context, query string, args ...interface{}) *sql.Row
+}
+
+// SupportsContext returns true if the handle of the dialect has the context
+// aware methods of *sql.DB, ExecContext and QueryContext among them.
+// model.SQLCommon does not require them, so the variants of the helpers that
+// take a context have to fall back to checking the context themselves when
+// this is false.
+func (q *QL) SupportsContext() bool {
+	_, ok := q.db.(contextHandle)
+	return ok
+}
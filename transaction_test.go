@@ -0,0 +1,285 @@
+package ql
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/akamajoris/ngorm/model"
+)
+
+func TestInTransaction(t *testing.T) {
+	q := openMemory(t)
+	db := q.db
+	if InTransaction(db) {
+		t.Error("expected *sql.DB not to be a transaction")
+	}
+	tx, err := db.Begin()
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer func() {
+		_ = tx.Rollback()
+	}()
+	if !InTransaction(&Tx{Tx: tx}) {
+		t.Error("expected *sql.Tx to be a transaction")
+	}
+	if _, err = (&Tx{Tx: tx}).Begin(); err != ErrNestedTransaction {
+		t.Errorf("expected %v got %v", ErrNestedTransaction, err)
+	}
+}
+
+func TestQL_RunInTransaction(t *testing.T) {
+	q := openMemory(t)
+	execTx(t, q.db, migration)
+	err := q.RunInTransaction(func(tx model.SQLCommon) error {
+		if !InTransaction(tx) {
+			t.Error("expected a transaction")
+		}
+		_, err := tx.Exec("INSERT INTO Items VALUES (1, 1, 1)")
+		return err
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	fail := errors.New("fail")
+	err = q.RunInTransaction(func(tx model.SQLCommon) error {
+		if _, err := tx.Exec("INSERT INTO Items VALUES (2, 2, 2)"); err != nil {
+			return err
+		}
+		return fail
+	})
+	if err != fail {
+		t.Errorf("expected %v got %v", fail, err)
+	}
+	c, err := q.CountRows("Items")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if c != 1 {
+		t.Errorf("expected 1 got %d", c)
+	}
+
+	// a dialect working on a transaction does not start another one
+	tx, err := q.db.Begin()
+	if err != nil {
+		t.Fatal(err)
+	}
+	inner := Memory()
+	inner.SetDB(&Tx{Tx: tx})
+	n, err := inner.Truncate("Items")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if n != 1 {
+		t.Errorf("expected 1 got %d", n)
+	}
+	if err = tx.Rollback(); err != nil {
+		t.Fatal(err)
+	}
+	c, err = q.CountRows("Items")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if c != 1 {
+		t.Errorf("expected the rollback to keep 1 record got %d", c)
+	}
+}
+
+// commitTx is a transaction handle that is not a Tx, like the ones other
+// packages wrap a *sql.Tx in.
+type commitTx struct {
+	*sql.Tx
+}
+
+func (c commitTx) Begin() (*sql.Tx, error) {
+	return nil, errors.New("commitTx: nested transaction")
+}
+
+func (c commitTx) Close() error {
+	return nil
+}
+
+func TestQL_TransactionHandle(t *testing.T) {
+	q := openMemory(t)
+	execTx(t, q.db, migration)
+	execTx(t, q.db, "INSERT INTO Items VALUES (1, 1, 1)")
+	tx, err := q.db.Begin()
+	if err != nil {
+		t.Fatal(err)
+	}
+	inner := Memory()
+	inner.SetDB(commitTx{Tx: tx})
+	if _, err = inner.ImportRows("Items", []map[string]interface{}{{"Qty": int64(2)}}); err != nil {
+		t.Fatal(err)
+	}
+	n, err := inner.Truncate("Items")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if n != 2 {
+		t.Errorf("expected 2 got %d", n)
+	}
+	err = inner.RunInTransaction(func(db model.SQLCommon) error {
+		if _, ok := db.(commitTx); !ok {
+			t.Errorf("expected the handle got %T", db)
+		}
+		return nil
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err = tx.Rollback(); err != nil {
+		t.Fatal(err)
+	}
+	c, err := q.CountRows("Items")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if c != 1 {
+		t.Errorf("expected the rollback to keep 1 record got %d", c)
+	}
+}
+
+func TestQL_RunInTransactionContext(t *testing.T) {
+	q := openMemory(t)
+	execTx(t, q.db, migration)
+	insert := func(tx model.SQLCommon) error {
+		_, err := tx.Exec("INSERT INTO Items VALUES (1, 1, 1)")
+		return err
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), 20*time.Millisecond)
+	defer cancel()
+	err := q.RunInTransactionContext(ctx, func(tx model.SQLCommon) error {
+		if err := insert(tx); err != nil {
+			return err
+		}
+		time.Sleep(50 * time.Millisecond)
+		return nil
+	})
+	if err != context.DeadlineExceeded {
+		t.Errorf("expected %v got %v", context.DeadlineExceeded, err)
+	}
+	n, err := q.CountRows("Items")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if n != 0 {
+		t.Errorf("expected the insert to be rolled back got %d records", n)
+	}
+
+	ctx, cancel = context.WithTimeout(context.Background(), time.Second)
+	defer cancel()
+	if err = q.RunInTransactionContext(ctx, insert); err != nil {
+		t.Fatal(err)
+	}
+	n, err = q.CountRows("Items")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if n != 1 {
+		t.Errorf("expected 1 got %d", n)
+	}
+}
+
+func TestQL_SetAutoTransaction(t *testing.T) {
+	q := openMemory(t)
+	execTx(t, q.db, migration)
+	values := map[string]interface{}{"OrderID": int64(1), "ProductID": int64(2), "Qty": int64(3)}
+	if _, err := q.ExecInsert(q.db, "Items", values); err == nil {
+		t.Error("expected an error for a write outside a transaction")
+	}
+	q.SetAutoTransaction(true)
+	if _, err := q.ExecInsert(q.db, "Items", values); err != nil {
+		t.Fatal(err)
+	}
+	set := map[string]interface{}{"Qty": int64(4)}
+	where := map[string]interface{}{"OrderID": int64(1)}
+	if _, err := q.ExecUpdate(q.db, "Items", set, where); err != nil {
+		t.Fatal(err)
+	}
+	qty, err := q.ScalarInt64("SELECT Qty FROM Items")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if qty != 4 {
+		t.Errorf("expected 4 got %d", qty)
+	}
+
+	// a transaction is used as is, Tx.Begin would fail if it was wrapped again.
+	tx, err := q.db.Begin()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err = q.ExecInsert(&Tx{Tx: tx}, "Items", values); err != nil {
+		t.Fatal(err)
+	}
+	if err = tx.Rollback(); err != nil {
+		t.Fatal(err)
+	}
+	n, err := q.ScalarInt64("SELECT count() FROM Items")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if n != 1 {
+		t.Errorf("expected 1 got %d", n)
+	}
+}
+
+func TestQL_SetTxHooks(t *testing.T) {
+	q := openMemory(t)
+	var begins, commits, rollbacks int
+	q.SetTxHooks(func() { begins++ }, func() { commits++ }, func() { rollbacks++ })
+	err := q.RunInTransaction(func(tx model.SQLCommon) error {
+		_, err := tx.Exec("CREATE TABLE hooks (n int)")
+		return err
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if begins != 1 || commits != 1 || rollbacks != 0 {
+		t.Errorf("expected 1 begin and 1 commit got %d, %d, %d", begins, commits, rollbacks)
+	}
+	fail := errors.New("fail")
+	err = q.RunInTransaction(func(tx model.SQLCommon) error {
+		return fail
+	})
+	if err != fail {
+		t.Errorf("expected %v got %v", fail, err)
+	}
+	if begins != 2 || commits != 1 || rollbacks != 1 {
+		t.Errorf("expected 2 begins, 1 commit and 1 rollback got %d, %d, %d", begins, commits, rollbacks)
+	}
+	if err = q.CreateIndex("hooks", "hooks_n", []string{"n"}, false); err != nil {
+		t.Fatal(err)
+	}
+	if begins != 3 || commits != 2 {
+		t.Errorf("expected the DDL helper to be reported got %d begins and %d commits", begins, commits)
+	}
+	q.SetTxHooks(nil, nil, nil)
+	if err = q.RemoveIndex("hooks", "hooks_n"); err != nil {
+		t.Fatal(err)
+	}
+	if begins != 3 {
+		t.Errorf("expected no more begins got %d", begins)
+	}
+}
+
+func TestQL_SupportsContext(t *testing.T) {
+	q := openMemory(t)
+	if !q.SupportsContext() {
+		t.Error("expected *sql.DB to support context")
+	}
+	db := q.db
+	q.SetDB(struct{ model.SQLCommon }{db})
+	if q.SupportsContext() {
+		t.Error("expected a plain model.SQLCommon not to support context")
+	}
+	q.SetDB(nil)
+	if q.SupportsContext() {
+		t.Error("expected no handle not to support context")
+	}
+}
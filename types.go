@@ -0,0 +1,347 @@
+package ql
+
+import (
+	"fmt"
+	"math/big"
+	"reflect"
+	"strings"
+	"time"
+
+	"github.com/akamajoris/ngorm/model"
+)
+
+// typeAliases maps the ql type aliases to the type they stand for.
+var typeAliases = map[string]string{
+	"byte":  "uint8",
+	"float": "float64",
+	"int":   "int64",
+	"rune":  "int32",
+	"uint":  "uint64",
+}
+
+// canonicalType returns the name ql uses for typ, resolving aliases and
+// ignoring case and surrounding white space.
+func canonicalType(typ string) string {
+	typ = strings.ToLower(strings.TrimSpace(typ))
+	if v, ok := typeAliases[typ]; ok {
+		return v
+	}
+	return typ
+}
+
+// typeFamily groups the ql types that convert into each other by widening.
+type typeFamily int
+
+const (
+	familyOther typeFamily = iota
+	familySigned
+	familyUnsigned
+	familyFloat
+	familyComplex
+)
+
+// numericTypes describes the numeric ql types. bits is the size of the
+// integer types and the precision of the mantissa for floating point ones.
+var numericTypes = map[string]struct {
+	family typeFamily
+	bits   int
+}{
+	"int8":       {familySigned, 8},
+	"int16":      {familySigned, 16},
+	"int32":      {familySigned, 32},
+	"int64":      {familySigned, 64},
+	"uint8":      {familyUnsigned, 8},
+	"uint16":     {familyUnsigned, 16},
+	"uint32":     {familyUnsigned, 32},
+	"uint64":     {familyUnsigned, 64},
+	"float32":    {familyFloat, 24},
+	"float64":    {familyFloat, 53},
+	"complex64":  {familyComplex, 24},
+	"complex128": {familyComplex, 53},
+}
+
+// TypesCompatible returns true if every value of the ql type from can be
+// converted to the type to without losing information. ql can not change the
+// type of a column in place, see RequiresRebuild, so this tells whether the
+// values survive when the table is rebuilt with the new type. Otherwise the
+// conversion has to be checked value by value, or done by hand.
+//
+// Integers widen into larger integers, into floating point types whose
+// mantissa can hold them and into bigint. Every integer and floating point
+// value is exactly representable as a bigrat. Anything else, like string to
+// int, is only compatible with itself.
+func TypesCompatible(from, to string) bool {
+	from, to = canonicalType(from), canonicalType(to)
+	if from == to {
+		return true
+	}
+	f, fok := numericTypes[from]
+	switch to {
+	case "bigint":
+		return fok && (f.family == familySigned || f.family == familyUnsigned)
+	case "bigrat":
+		return from == "bigint" || fok && f.family != familyComplex
+	}
+	t, tok := numericTypes[to]
+	if !fok || !tok {
+		return false
+	}
+	switch f.family {
+	case familySigned:
+		return t.family != familyUnsigned && f.bits <= t.bits
+	case familyUnsigned:
+		if t.family == familySigned {
+			return f.bits < t.bits
+		}
+		return f.bits <= t.bits
+	case familyFloat:
+		return (t.family == familyFloat || t.family == familyComplex) && f.bits <= t.bits
+	case familyComplex:
+		return t.family == familyComplex && f.bits <= t.bits
+	}
+	return false
+}
+
+// qlTypes are the column types ql accepts, aliases included.
+var qlTypes = map[string]bool{
+	"bigint": true, "bigrat": true, "blob": true, "bool": true, "byte": true,
+	"complex128": true, "complex64": true, "duration": true, "float": true,
+	"float32": true, "float64": true, "int": true, "int16": true, "int32": true,
+	"int64": true, "int8": true, "rune": true, "string": true, "time": true,
+	"uint": true, "uint16": true, "uint32": true, "uint64": true, "uint8": true,
+}
+
+// isValidQLType returns true if s is a valid column definition without the
+// column name, that is a ql type optionally followed by a constraint, either
+// NOT NULL or an expression, and a DEFAULT expression.
+//
+// Expressions are not parsed, they only have to be non empty, balanced and
+// free of statement separators.
+func isValidQLType(s string) bool {
+	words, ok := constraintWords(s)
+	if !ok || len(words) == 0 || !qlTypes[words[0]] {
+		return false
+	}
+	words = words[1:]
+	constraint := words
+	var def []string
+	for i, w := range words {
+		if strings.EqualFold(w, "DEFAULT") {
+			constraint, def = words[:i], words[i+1:]
+			if len(def) == 0 {
+				return false
+			}
+			break
+		}
+	}
+	if len(constraint) > 0 && strings.EqualFold(constraint[0], "NOT") {
+		return len(constraint) == 2 && strings.EqualFold(constraint[1], "NULL")
+	}
+	for _, w := range append(constraint, def...) {
+		if strings.EqualFold(w, "UNIQUE") || strings.EqualFold(w, "DEFAULT") {
+			return false
+		}
+	}
+	return true
+}
+
+// constraintWords splits s at the white space outside of parentheses and
+// literals. It returns false if s has unbalanced parentheses, an unterminated
+// literal or a semicolon.
+func constraintWords(s string) ([]string, bool) {
+	var words []string
+	depth, start := 0, -1
+	for i := 0; i < len(s); i++ {
+		c := s[i]
+		if start < 0 && c != ' ' && c != '\t' && c != '\n' {
+			start = i
+		}
+		switch c {
+		case '"', '`', '\'':
+			end := i + 1
+			for ; end < len(s) && s[end] != c; end++ {
+				if s[end] == '\\' && c != '`' {
+					end++
+				}
+			}
+			if end >= len(s) {
+				return nil, false
+			}
+			i = end
+		case '(':
+			depth++
+		case ')':
+			depth--
+			if depth < 0 {
+				return nil, false
+			}
+		case ';':
+			return nil, false
+		case ' ', '\t', '\n':
+			if depth == 0 && start >= 0 {
+				words = append(words, s[start:i])
+				start = -1
+			}
+		}
+	}
+	if depth != 0 {
+		return nil, false
+	}
+	if start >= 0 {
+		words = append(words, s[start:])
+	}
+	return words, true
+}
+
+// uniformKinds are the integer kinds SetUniformInt64 maps to int64. uint and
+// uint64 are left alone since their values do not all fit.
+var uniformKinds = map[reflect.Kind]bool{
+	reflect.Int:    true,
+	reflect.Int8:   true,
+	reflect.Int16:  true,
+	reflect.Int32:  true,
+	reflect.Int64:  true,
+	reflect.Uint8:  true,
+	reflect.Uint16: true,
+	reflect.Uint32: true,
+}
+
+// SetUniformInt64 makes DataTypeOf use int64 columns for all integer fields
+// instead of columns as wide as the field, so every integer column scans into
+// an int64. Values take more space. The unsigned 64 bit kinds keep their uint64
+// columns.
+func (q *QL) SetUniformInt64(ok bool) {
+	q.uniformInt64 = ok
+}
+
+// SetNullByDefault decides whether columns accept NULL unless told otherwise,
+// which is the default. When ok is false DataTypeOf declares columns NOT NULL
+// unless the field is tagged null, is one of the sql.Null types, like
+// sql.NullString or sql.Null[T], or is a primary key since those are left for
+// ngorm to fill in. A not null tag always makes the column NOT NULL.
+func (q *QL) SetNullByDefault(ok bool) {
+	q.notNullByDefault = !ok
+}
+
+// SetDefaultUntypedColumn sets the ql type DataTypeOf uses for interface{}
+// fields, whose type can not be inferred from the struct, blob or string for
+// instance. An empty qlType, the default, makes DataTypeOf return an error for
+// those fields.
+func (q *QL) SetDefaultUntypedColumn(qlType string) {
+	q.untypedColumn = qlType
+}
+
+// hasNullability returns true if field states whether its column accepts NULL.
+func hasNullability(field *model.StructField) bool {
+	_, notNull := field.TagSettings["NOT NULL"]
+	_, null := field.TagSettings["NULL"]
+	return notNull || null
+}
+
+// isNullType returns true if t is one of the nullable types of database/sql,
+// like sql.NullInt64 or the generic sql.Null[T]. DataTypeOf maps them to the
+// type of the value they wrap.
+func isNullType(t reflect.Type) bool {
+	for t.Kind() == reflect.Ptr {
+		t = t.Elem()
+	}
+	return t.PkgPath() == "database/sql" && strings.HasPrefix(t.Name(), "Null")
+}
+
+var timeType = reflect.TypeOf(time.Time{})
+
+// IsBlobType returns true if qlType, as reported by ListColumns for instance,
+// is blob.
+func IsBlobType(qlType string) bool {
+	return canonicalType(qlType) == "blob"
+}
+
+// IsTimeType returns true if qlType is time. duration is not a time type.
+func IsTimeType(qlType string) bool {
+	return canonicalType(qlType) == "time"
+}
+
+// IsNumericType returns true if qlType is one of the integer, floating point or
+// complex types, or bigint or bigrat.
+func IsNumericType(qlType string) bool {
+	typ := canonicalType(qlType)
+	if _, ok := numericTypes[typ]; ok {
+		return true
+	}
+	return typ == "bigint" || typ == "bigrat"
+}
+
+// ClassifyValue returns the name of the ql type of v, a value read from the
+// database like the ones of ExportRows: int64, float64, string, blob, time,
+// duration, bigint, bigrat and so on. nil, NULL in the database, is reported
+// as null and values of other types as unknown.
+//
+// database/sql hands string, bigint and bigrat values to interface{}
+// destinations as []byte, which is classified as blob. ExportRows decodes them
+// using the column types.
+func ClassifyValue(v interface{}) string {
+	switch x := v.(type) {
+	case nil:
+		return "null"
+	case []byte:
+		return "blob"
+	case time.Time:
+		return "time"
+	case time.Duration:
+		return "duration"
+	case *big.Int, big.Int:
+		return "bigint"
+	case *big.Rat, big.Rat:
+		return "bigrat"
+	case bool, string, int8, int16, int32, int64, uint8, uint16, uint32, uint64,
+		float32, float64, complex64, complex128:
+		return reflect.TypeOf(x).Name()
+	case int:
+		return "int64"
+	case uint:
+		return "uint64"
+	}
+	return "unknown"
+}
+
+// scanTypes are the Go types values of each ql type are scanned into.
+var scanTypes = map[string]reflect.Type{
+	"bigint":     reflect.TypeOf((*big.Int)(nil)),
+	"bigrat":     reflect.TypeOf((*big.Rat)(nil)),
+	"blob":       reflect.TypeOf([]byte(nil)),
+	"bool":       reflect.TypeOf(false),
+	"complex64":  reflect.TypeOf(complex64(0)),
+	"complex128": reflect.TypeOf(complex128(0)),
+	"duration":   reflect.TypeOf(time.Duration(0)),
+	"float32":    reflect.TypeOf(float32(0)),
+	"float64":    reflect.TypeOf(float64(0)),
+	"int8":       reflect.TypeOf(int8(0)),
+	"int16":      reflect.TypeOf(int16(0)),
+	"int32":      reflect.TypeOf(int32(0)),
+	"int64":      reflect.TypeOf(int64(0)),
+	"string":     reflect.TypeOf(""),
+	"time":       timeType,
+	"uint8":      reflect.TypeOf(uint8(0)),
+	"uint16":     reflect.TypeOf(uint16(0)),
+	"uint32":     reflect.TypeOf(uint32(0)),
+	"uint64":     reflect.TypeOf(uint64(0)),
+}
+
+// ScanTargetType returns the Go type to scan the values of columnName of
+// tableName into, int64 for an int64 column, time.Time for a time column and
+// so on. Nullable values need a pointer to it.
+//
+// bigint and bigrat values are reported as *big.Int and *big.Rat, which do not
+// implement sql.Scanner: database/sql hands them out as text, to be parsed
+// with SetString. ExportRows does that already.
+func (q *QL) ScanTargetType(tableName, columnName string) (reflect.Type, error) {
+	typ, err := q.ColumnType(tableName, columnName)
+	if err != nil {
+		return nil, err
+	}
+	t, ok := scanTypes[canonicalType(typ)]
+	if !ok {
+		return nil, fmt.Errorf("ql: column %s of table %s has unknown type %s", columnName, tableName, typ)
+	}
+	return t, nil
+}
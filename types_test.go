@@ -0,0 +1,405 @@
+package ql
+
+import (
+	"math/big"
+	"reflect"
+	"testing"
+	"time"
+)
+
+func TestTypesCompatible(t *testing.T) {
+	sample := []struct {
+		from, to   string
+		compatible bool
+	}{
+		{"int8", "int64", true},
+		{"int", "int64", true},
+		{"int32", "int16", false},
+		{"uint8", "int16", true},
+		{"uint16", "int16", false},
+		{"int8", "uint64", false},
+		{"uint32", "uint64", true},
+		{"int16", "float32", true},
+		{"int32", "float32", false},
+		{"int32", "float64", true},
+		{"int64", "float64", false},
+		{"float32", "float64", true},
+		{"float64", "float32", false},
+		{"float64", "complex128", true},
+		{"int64", "bigint", true},
+		{"bigint", "int64", false},
+		{"bigint", "bigrat", true},
+		{"float64", "bigrat", true},
+		{"string", "int", false},
+		{"int", "string", false},
+		{"string", "string", true},
+		{"time", "duration", false},
+		{"blob", "string", false},
+		{"BLOB", "blob", true},
+	}
+	for _, v := range sample {
+		o := TypesCompatible(v.from, v.to)
+		if o != v.compatible {
+			t.Errorf("%s -> %s: expected %v got %v", v.from, v.to, v.compatible, o)
+		}
+	}
+}
+
+func TestIsValidQLType(t *testing.T) {
+	sample := []struct {
+		typ   string
+		valid bool
+	}{
+		{"int64", true},
+		{"string NOT NULL", true},
+		{"string not null DEFAULT \"none\"", true},
+		{"int64 DEFAULT 1", true},
+		{"int64 Qty > 0", true},
+		{"int64 Qty > 0 DEFAULT (1 + 2)", true},
+		{"string DEFAULT \"a b; c\"", true},
+		{"varchar(255)", false},
+		{"", false},
+		{"int64 NOT", false},
+		{"int64 NOT NULL Qty > 0", false},
+		{"int64 DEFAULT", false},
+		{"int64 DEFAULT (1", false},
+		{"int64 DEFAULT 1)", false},
+		{"string DEFAULT \"open", false},
+		{"int64 UNIQUE", false},
+		{"int64 DEFAULT 1; DROP TABLE x", false},
+		{"int64 DEFAULT 1 DEFAULT 2", false},
+	}
+	for _, v := range sample {
+		if o := isValidQLType(v.typ); o != v.valid {
+			t.Errorf("%q: expected %v got %v", v.typ, v.valid, o)
+		}
+	}
+}
+
+type Checked struct {
+	ID      int64
+	Name    string `sql:"not null"`
+	Status  string `sql:"default:\"new\""`
+	Broken  string `sql:"default:(1"`
+	Code    string `sql:"unique"`
+	Counter int64  `sql:"not null;default:0"`
+}
+
+func TestQL_DataTypeOfValidation(t *testing.T) {
+	q := Memory()
+	exp := map[string]string{
+		"ID":      "int64",
+		"Name":    "string NOT NULL",
+		"Status":  "string DEFAULT \"new\"",
+		"Counter": "int64 NOT NULL  DEFAULT 0",
+	}
+	for _, f := range modelFields(t, &Checked{}) {
+		s, err := q.DataTypeOf(f)
+		switch f.Name {
+		case "Broken", "Code":
+			if err == nil {
+				t.Errorf("%s: expected an error got %s", f.Name, s)
+			}
+		default:
+			if err != nil {
+				t.Errorf("%s: %v", f.Name, err)
+			} else if s != exp[f.Name] {
+				t.Errorf("%s: expected %s got %s", f.Name, exp[f.Name], s)
+			}
+		}
+	}
+}
+
+type Widths struct {
+	A int8
+	B int16
+	C int32
+	D int64
+	E int
+	F uint16
+	G uint64
+}
+
+func TestQL_SetUniformInt64(t *testing.T) {
+	q := Memory()
+	fields := modelFields(t, &Widths{})
+	types := func() map[string]string {
+		o := make(map[string]string)
+		for _, f := range fields {
+			s, err := q.DataTypeOf(f)
+			if err != nil {
+				t.Fatal(err)
+			}
+			o[f.Name] = s
+		}
+		return o
+	}
+	exp := map[string]string{
+		"A": "int8", "B": "int16", "C": "int32", "D": "int64",
+		"E": "int", "F": "uint16", "G": "uint64",
+	}
+	if o := types(); !reflect.DeepEqual(o, exp) {
+		t.Errorf("expected %v got %v", exp, o)
+	}
+	q.SetUniformInt64(true)
+	exp = map[string]string{
+		"A": "int64", "B": "int64", "C": "int64", "D": "int64",
+		"E": "int64", "F": "int64", "G": "uint64",
+	}
+	if o := types(); !reflect.DeepEqual(o, exp) {
+		t.Errorf("expected %v got %v", exp, o)
+	}
+}
+
+type Contact struct {
+	ID    int64
+	Name  string
+	Email string `sql:"not null"`
+	Phone string `sql:"null"`
+	Score int64  `sql:"default:0"`
+}
+
+func TestQL_SetNullByDefault(t *testing.T) {
+	q := Memory()
+	fields := modelFields(t, &Contact{})
+	types := func() map[string]string {
+		o := make(map[string]string)
+		for _, f := range fields {
+			s, err := q.DataTypeOf(f)
+			if err != nil {
+				t.Fatal(err)
+			}
+			o[f.Name] = s
+		}
+		return o
+	}
+	exp := map[string]string{
+		"ID":    "int64",
+		"Name":  "string",
+		"Email": "string NOT NULL",
+		"Phone": "string",
+		"Score": "int64 DEFAULT 0",
+	}
+	if o := types(); !reflect.DeepEqual(o, exp) {
+		t.Errorf("expected %v got %v", exp, o)
+	}
+	q.SetNullByDefault(false)
+	exp = map[string]string{
+		"ID":    "int64",
+		"Name":  "string NOT NULL",
+		"Email": "string NOT NULL",
+		"Phone": "string",
+		"Score": "int64 NOT NULL DEFAULT 0",
+	}
+	if o := types(); !reflect.DeepEqual(o, exp) {
+		t.Errorf("expected %v got %v", exp, o)
+	}
+}
+
+type Timestamp time.Time
+
+type Event struct {
+	ID int64
+	At Timestamp
+}
+
+func TestQL_DataTypeOfTimeAlias(t *testing.T) {
+	q := openMemory(t)
+	for _, f := range modelFields(t, &Event{}) {
+		if f.Name != "At" {
+			continue
+		}
+		s, err := q.DataTypeOf(f)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if s != "time" {
+			t.Errorf("expected time got %s", s)
+		}
+	}
+	execTx(t, q.db, "CREATE TABLE events (At time)")
+	at := Timestamp(time.Date(2017, 3, 4, 5, 6, 7, 8, time.UTC))
+	execTx(t, q.db, "INSERT INTO events VALUES ($1)", time.Time(at))
+	var o time.Time
+	if err := q.db.QueryRow("SELECT At FROM events").Scan(&o); err != nil {
+		t.Fatal(err)
+	}
+	if !time.Time(at).Equal(o) {
+		t.Errorf("expected %v got %v", time.Time(at), o)
+	}
+}
+
+func TestTypeClassifiers(t *testing.T) {
+	sample := []struct {
+		typ                   string
+		blob, isTime, numeric bool
+	}{
+		{"blob", true, false, false},
+		{"BLOB", true, false, false},
+		{"time", false, true, false},
+		{"duration", false, false, false},
+		{"int", false, false, true},
+		{"uint8", false, false, true},
+		{"byte", false, false, true},
+		{"float32", false, false, true},
+		{"complex128", false, false, true},
+		{"bigint", false, false, true},
+		{"bigrat", false, false, true},
+		{"string", false, false, false},
+		{"bool", false, false, false},
+		{"varchar", false, false, false},
+		{"", false, false, false},
+	}
+	for _, v := range sample {
+		if o := IsBlobType(v.typ); o != v.blob {
+			t.Errorf("IsBlobType(%q): expected %v got %v", v.typ, v.blob, o)
+		}
+		if o := IsTimeType(v.typ); o != v.isTime {
+			t.Errorf("IsTimeType(%q): expected %v got %v", v.typ, v.isTime, o)
+		}
+		if o := IsNumericType(v.typ); o != v.numeric {
+			t.Errorf("IsNumericType(%q): expected %v got %v", v.typ, v.numeric, o)
+		}
+	}
+}
+
+type Flexible struct {
+	ID    int64
+	Value interface{}
+}
+
+func TestQL_SetDefaultUntypedColumn(t *testing.T) {
+	q := Memory()
+	value := modelFields(t, &Flexible{})[1]
+	if s, err := q.DataTypeOf(value); err == nil {
+		t.Errorf("expected an error got %s", s)
+	}
+	q.SetDefaultUntypedColumn("blob")
+	s, err := q.DataTypeOf(value)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if s != "blob" {
+		t.Errorf("expected blob got %s", s)
+	}
+	q.SetDefaultUntypedColumn("string")
+	s, err = q.DataTypeOf(value)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if s != "string" {
+		t.Errorf("expected string got %s", s)
+	}
+}
+
+func TestClassifyValue(t *testing.T) {
+	sample := []struct {
+		v   interface{}
+		exp string
+	}{
+		{nil, "null"},
+		{int64(1), "int64"},
+		{1, "int64"},
+		{uint8(1), "uint8"},
+		{1.5, "float64"},
+		{"a", "string"},
+		{true, "bool"},
+		{[]byte("a"), "blob"},
+		{time.Now(), "time"},
+		{time.Second, "duration"},
+		{big.NewInt(1), "bigint"},
+		{big.NewRat(1, 3), "bigrat"},
+		{struct{}{}, "unknown"},
+	}
+	for _, v := range sample {
+		if o := ClassifyValue(v.v); o != v.exp {
+			t.Errorf("%#v: expected %s got %s", v.v, v.exp, o)
+		}
+	}
+
+	q := openMemory(t)
+	execTx(t, q.db, "CREATE TABLE scanned (n int, at time, data blob, ratio bigrat)")
+	execTx(t, q.db, "INSERT INTO scanned VALUES (1, now(), blob(\"a\"), bigrat(\"1/3\")), (NULL, NULL, NULL, NULL)")
+	rows, err := q.ExportRows("scanned")
+	if err != nil {
+		t.Fatal(err)
+	}
+	exp := map[string]string{"n": "int64", "at": "time", "data": "blob", "ratio": "bigrat"}
+	for k, typ := range exp {
+		if o := ClassifyValue(rows[0][k]); o != typ {
+			t.Errorf("%s: expected %s got %s", k, typ, o)
+		}
+		if o := ClassifyValue(rows[1][k]); o != "null" {
+			t.Errorf("%s: expected null got %s", k, o)
+		}
+	}
+}
+
+type Stamped struct {
+	ID        int64
+	Name      string
+	CreatedAt time.Time `sql:"default:now"`
+}
+
+func TestQL_DataTypeOfDefaultNow(t *testing.T) {
+	q := openMemory(t)
+	fields := modelFields(t, &Stamped{})
+	s, err := q.DataTypeOf(fields[2])
+	if err != nil {
+		t.Fatal(err)
+	}
+	if s != "time DEFAULT now()" {
+		t.Errorf("expected time DEFAULT now() got %s", s)
+	}
+	create, err := q.CreateTableSQL("stamped", fields)
+	if err != nil {
+		t.Fatal(err)
+	}
+	execTx(t, q.db, create)
+	before := time.Now().Add(-time.Second)
+	execTx(t, q.db, "INSERT INTO stamped (name) VALUES ($1)", "a")
+	var at time.Time
+	if err = q.db.QueryRow("SELECT created_at FROM stamped").Scan(&at); err != nil {
+		t.Fatal(err)
+	}
+	if at.Before(before) || at.After(time.Now()) {
+		t.Errorf("expected the current time got %v", at)
+	}
+}
+
+func TestQL_ScanTargetType(t *testing.T) {
+	q := openMemory(t)
+	execTx(t, q.db, "CREATE TABLE files (name string, data blob, created time, ratio bigrat, size int, small int8, ttl duration)")
+	sample := map[string]reflect.Type{
+		"name":    reflect.TypeOf(""),
+		"data":    reflect.TypeOf([]byte(nil)),
+		"created": reflect.TypeOf(time.Time{}),
+		"ratio":   reflect.TypeOf(&big.Rat{}),
+		"size":    reflect.TypeOf(int64(0)),
+		"small":   reflect.TypeOf(int8(0)),
+		"ttl":     reflect.TypeOf(time.Duration(0)),
+	}
+	for column, exp := range sample {
+		o, err := q.ScanTargetType("files", column)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if o != exp {
+			t.Errorf("%s: expected %v got %v", column, exp, o)
+		}
+	}
+	execTx(t, q.db, "INSERT INTO files (small, ttl, created) VALUES (3, duration(\"2s\"), now())")
+	for _, column := range []string{"small", "ttl", "created"} {
+		typ, err := q.ScanTargetType("files", column)
+		if err != nil {
+			t.Fatal(err)
+		}
+		dst := reflect.New(typ)
+		if err = q.db.QueryRow("SELECT " + column + " FROM files").Scan(dst.Interface()); err != nil {
+			t.Errorf("%s: %v", column, err)
+		}
+	}
+	if _, err := q.ScanTargetType("files", "missing"); err == nil {
+		t.Error("expected an error for a missing column")
+	}
+}
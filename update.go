@@ -0,0 +1,52 @@
+package ql
+
+import (
+	"database/sql"
+	"fmt"
+	"strings"
+
+	"github.com/akamajoris/ngorm/model"
+)
+
+// UpdateSQL returns the UPDATE statement that sets the columns of set on the
+// records of tableName matching where, and its arguments. The arguments of set
+// come first, followed by the ones of where. Columns are ordered by name in both
+// clauses so the statement is the same for the same columns.
+//
+// A nil value in where matches NULL. An empty where updates all records.
+func (q *QL) UpdateSQL(tableName string, set map[string]interface{}, where map[string]interface{}) (string, []interface{}, error) {
+	if len(set) == 0 {
+		return "", nil, fmt.Errorf("ql: update %s: no columns to set", tableName)
+	}
+	var args []interface{}
+	var assign []string
+	for _, c := range sortedKeys(set) {
+		args = append(args, set[c])
+		assign = append(assign, fmt.Sprintf("%s = %s", q.Quote(c), q.BindVar(len(args))))
+	}
+	query := fmt.Sprintf("UPDATE %s SET %s", q.Quote(tableName), strings.Join(assign, ", "))
+	if len(where) == 0 {
+		return query, args, nil
+	}
+	var conds []string
+	for _, c := range sortedKeys(where) {
+		v := where[c]
+		if v == nil {
+			conds = append(conds, q.Quote(c)+" IS NULL")
+			continue
+		}
+		args = append(args, v)
+		conds = append(conds, fmt.Sprintf("%s == %s", q.Quote(c), q.BindVar(len(args))))
+	}
+	return query + " WHERE " + strings.Join(conds, " && "), args, nil
+}
+
+// ExecUpdate executes the statement of UpdateSQL on db. See SetAutoTransaction
+// for running it on a handle that is not a transaction.
+func (q *QL) ExecUpdate(db model.SQLCommon, tableName string, set map[string]interface{}, where map[string]interface{}) (sql.Result, error) {
+	query, args, err := q.UpdateSQL(tableName, set, where)
+	if err != nil {
+		return nil, err
+	}
+	return q.execWrite(db, query, args...)
+}
@@ -0,0 +1,47 @@
+package ql
+
+import (
+	"reflect"
+	"testing"
+)
+
+func TestQL_UpdateSQL(t *testing.T) {
+	q := openMemory(t)
+	s, args, err := q.UpdateSQL("Items",
+		map[string]interface{}{"Qty": int64(5), "ProductID": int64(7)},
+		map[string]interface{}{"OrderID": int64(1), "Qty": nil},
+	)
+	if err != nil {
+		t.Fatal(err)
+	}
+	exp := "UPDATE Items SET ProductID = $1, Qty = $2 WHERE OrderID == $3 && Qty IS NULL"
+	if s != exp {
+		t.Errorf("expected %s got %s", exp, s)
+	}
+	if !reflect.DeepEqual(args, []interface{}{int64(7), int64(5), int64(1)}) {
+		t.Errorf("expected [7 5 1] got %v", args)
+	}
+
+	execTx(t, q.db, migration)
+	execTx(t, q.db, "INSERT INTO Items VALUES (1, 1, NULL), (2, 2, NULL)")
+	execTx(t, q.db, s, args...)
+	n, err := q.ScalarInt64("SELECT count() FROM Items WHERE ProductID == 7 && Qty == 5")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if n != 1 {
+		t.Errorf("expected 1 updated record got %d", n)
+	}
+
+	q.SetSQLBuilder(bracketBuilder{})
+	s, _, err = q.UpdateSQL("Items", map[string]interface{}{"Qty": 1}, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if exp = "UPDATE [Items] SET [Qty] = $1"; s != exp {
+		t.Errorf("expected %s got %s", exp, s)
+	}
+	if _, _, err = q.UpdateSQL("Items", nil, map[string]interface{}{"Qty": 1}); err == nil {
+		t.Error("expected an error for an empty SET")
+	}
+}
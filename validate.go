@@ -0,0 +1,52 @@
+package ql
+
+import (
+	"fmt"
+	"time"
+)
+
+// ValidateColumnValues returns the id() of the records of tableName whose value
+// of columnName can not be converted to expectedType, in id() order. Run it
+// before changing the type of a column to find the records that would stop the
+// conversion. NULL converts to any type.
+//
+// Values are converted through their text form, the one ExportCSV writes, and
+// parsed like ImportCSV does, so the string "12" converts to int8 but 300 does
+// not. time converts to the integer types wide enough to hold its Unix time in
+// seconds.
+func (q *QL) ValidateColumnValues(tableName, columnName, expectedType string) (invalidIDs []int64, err error) {
+	typ, err := q.ColumnType(tableName, columnName)
+	if err != nil {
+		return nil, err
+	}
+	expectedType = canonicalType(expectedType)
+	if !qlTypes[expectedType] {
+		return nil, fmt.Errorf("ql: invalid type %q", expectedType)
+	}
+	cols := []Column{{Name: columnName, Type: typ}}
+	err = q.eachRow(tableName, cols, func(id int64, values []interface{}) error {
+		if !convertible(values[0], expectedType) {
+			invalidIDs = append(invalidIDs, id)
+		}
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+	return invalidIDs, nil
+}
+
+// convertible returns true if v, as decoded by decodeValue, can be stored in a
+// column of the canonical type typ.
+func convertible(v interface{}, typ string) bool {
+	if v == nil {
+		return true
+	}
+	if t, ok := v.(time.Time); ok {
+		if n, ok := numericTypes[typ]; ok && n.family != familyFloat && n.family != familyComplex {
+			return inRange(t.Unix(), n.family, n.bits)
+		}
+	}
+	_, err := parseCSV(typ, formatCSV(v))
+	return err == nil
+}
@@ -0,0 +1,50 @@
+package ql
+
+import (
+	"reflect"
+	"testing"
+	"time"
+)
+
+func TestQL_ValidateColumnValues(t *testing.T) {
+	q := openMemory(t)
+	execTx(t, q.db, "CREATE TABLE readings (value string, at time)")
+	ids, err := q.BatchInsertReturningIDs(q.db, "readings", []string{"value", "at"}, [][]interface{}{
+		{"12", time.Date(2017, 3, 1, 0, 0, 0, 0, time.UTC)},
+		{"300", nil},
+		{"abc", time.Date(1960, 1, 1, 0, 0, 0, 0, time.UTC)},
+		{nil, nil},
+		{"-5", time.Date(2200, 1, 1, 0, 0, 0, 0, time.UTC)},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	sample := []struct {
+		column, typ string
+		invalid     []int64
+	}{
+		{"value", "int8", []int64{ids[1], ids[2]}},
+		{"value", "int64", []int64{ids[2]}},
+		{"value", "uint", []int64{ids[2], ids[4]}},
+		{"value", "string", nil},
+		{"at", "int64", nil},
+		{"at", "uint32", []int64{ids[2], ids[4]}},
+		{"at", "int32", []int64{ids[4]}},
+		{"at", "bool", []int64{ids[0], ids[2], ids[4]}},
+	}
+	for _, v := range sample {
+		o, err := q.ValidateColumnValues("readings", v.column, v.typ)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if !reflect.DeepEqual(o, v.invalid) {
+			t.Errorf("%s to %s: expected %v got %v", v.column, v.typ, v.invalid, o)
+		}
+	}
+	if _, err = q.ValidateColumnValues("readings", "missing", "int64"); err == nil {
+		t.Error("expected an error for a missing column")
+	}
+	if _, err = q.ValidateColumnValues("readings", "value", "varchar"); err == nil {
+		t.Error("expected an error for an invalid type")
+	}
+}
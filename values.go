@@ -0,0 +1,415 @@
+package ql
+
+import (
+	"database/sql"
+	"database/sql/driver"
+	"encoding/base64"
+	"fmt"
+	"math"
+	"math/big"
+	"reflect"
+	"strconv"
+	"time"
+)
+
+// SetBlobAsBase64 makes the dialect store []byte fields as base64 encoded
+// strings instead of blobs, which keeps the database readable by text based
+// tools. Values must then go through BindBlob and ScanBlob. The insert and
+// import helpers encode []byte values themselves, and ExportRows and ExportCSV
+// decode the values of blob columns.
+func (q *QL) SetBlobAsBase64(ok bool) {
+	q.blobAsBase64 = ok
+}
+
+// DefaultMaxBlobSize is the blob size MaxBlobSize reports unless another one
+// is set with SetMaxBlobSize. ql itself has no fixed limit but it holds whole
+// values in memory and rewrites them on every update, so this is a
+// conservative bound rather than a hard one.
+const DefaultMaxBlobSize int64 = 64 << 20
+
+// MaxBlobSize returns the largest blob, in bytes, the dialect considers safe
+// to store.
+func (q *QL) MaxBlobSize() int64 {
+	if q.maxBlobSize > 0 {
+		return q.maxBlobSize
+	}
+	return DefaultMaxBlobSize
+}
+
+// SetMaxBlobSize sets the size reported by MaxBlobSize, zero restores
+// DefaultMaxBlobSize.
+func (q *QL) SetMaxBlobSize(n int64) {
+	q.maxBlobSize = n
+}
+
+// SetEnforceBlobSize makes BindBlob reject blobs larger than MaxBlobSize
+// instead of handing them to the driver. The check also applies to the []byte
+// values written by InsertSQL, ExecInsert, BatchInsert, ImportRows and
+// ImportCSV.
+func (q *QL) SetEnforceBlobSize(ok bool) {
+	q.enforceBlobSize = ok
+}
+
+// BindBlob returns the value to pass as the argument for a []byte column.
+func (q *QL) BindBlob(b []byte) (interface{}, error) {
+	if q.enforceBlobSize && int64(len(b)) > q.MaxBlobSize() {
+		return nil, fmt.Errorf("ql: blob of %d bytes exceeds the maximum of %d bytes", len(b), q.MaxBlobSize())
+	}
+	return q.encodeBlob(b), nil
+}
+
+func (q *QL) encodeBlob(b []byte) interface{} {
+	if b == nil {
+		return nil
+	}
+	if q.blobAsBase64 {
+		return base64.StdEncoding.EncodeToString(b)
+	}
+	return b
+}
+
+// ScanBlob decodes src, a value read from a []byte column, into dst. NULL
+// is decoded as a nil slice.
+func (q *QL) ScanBlob(src interface{}, dst *[]byte) error {
+	var b []byte
+	switch v := src.(type) {
+	case nil:
+		*dst = nil
+		return nil
+	case []byte:
+		b = v
+	case string:
+		b = []byte(v)
+	default:
+		return fmt.Errorf("ql: cannot scan %T into []byte", src)
+	}
+	if !q.blobAsBase64 {
+		*dst = append([]byte(nil), b...)
+		return nil
+	}
+	o := make([]byte, base64.StdEncoding.DecodedLen(len(b)))
+	n, err := base64.StdEncoding.Decode(o, b)
+	if err != nil {
+		return fmt.Errorf("ql: invalid base64 blob: %v", err)
+	}
+	*dst = o[:n]
+	return nil
+}
+
+// SetNonFiniteFloatAsNull makes BindFloat bind NaN and infinities as NULL
+// instead of rejecting them.
+func (q *QL) SetNonFiniteFloatAsNull(ok bool) {
+	q.nonFiniteAsNull = ok
+}
+
+// BindFloat returns the argument to pass for v. NaN and infinities break the
+// ordering and comparison of a float column, so they are rejected unless
+// SetNonFiniteFloatAsNull is in effect, in which case they are stored as NULL.
+func (q *QL) BindFloat(v float64) (interface{}, error) {
+	if math.IsNaN(v) || math.IsInf(v, 0) {
+		if q.nonFiniteAsNull {
+			return nil, nil
+		}
+		return nil, fmt.Errorf("ql: cannot store non finite float %v", v)
+	}
+	return v, nil
+}
+
+// Transformer converts the values of a column on their way in and out of the
+// database.
+type Transformer interface {
+	// OnStore is called with the value that is about to be written.
+	OnStore(v interface{}) (interface{}, error)
+
+	// OnLoad is called with the value that was read from the database.
+	OnLoad(v interface{}) (interface{}, error)
+}
+
+// RegisterTransformer installs t for columnName of tableName. Values for the
+// column pass through t in BindValue and ScanValue, and in the helpers that
+// write and read records: InsertSQL, ExecInsert, BatchInsert, ImportRows and
+// ImportCSV on the way in, ExportRows and ExportCSV on the way out, so values
+// bound with BindValue must not be passed to those. Registering a transformer
+// for a column replaces the previous one, passing nil removes it.
+//
+// Transformers are not safe to register while the dialect is in use.
+func (q *QL) RegisterTransformer(tableName, columnName string, t Transformer) {
+	key := tableName + "." + columnName
+	if t == nil {
+		delete(q.transformers, key)
+		return
+	}
+	if q.transformers == nil {
+		q.transformers = make(map[string]Transformer)
+	}
+	q.transformers[key] = t
+}
+
+func (q *QL) transformer(tableName, columnName string) Transformer {
+	return q.transformers[tableName+"."+columnName]
+}
+
+// BindValue returns the argument to pass for v when it is written to
+// columnName of tableName, applying the registered transformer if any.
+func (q *QL) BindValue(tableName, columnName string, v interface{}) (interface{}, error) {
+	if t := q.transformer(tableName, columnName); t != nil {
+		return t.OnStore(v)
+	}
+	return v, nil
+}
+
+// ScanValue returns the value for src which was read from columnName of
+// tableName, applying the registered transformer if any.
+func (q *QL) ScanValue(tableName, columnName string, src interface{}) (interface{}, error) {
+	if t := q.transformer(tableName, columnName); t != nil {
+		return t.OnLoad(src)
+	}
+	return src, nil
+}
+
+// ScanTime stores src, a value read from a time column, in dst. NULL can not
+// be scanned into a time.Time, so ScanTime sets null to true and zeroes dst
+// instead. Text values are parsed as RFC 3339 timestamps.
+func ScanTime(src interface{}, dst *time.Time, null *bool) error {
+	switch v := src.(type) {
+	case nil:
+		*dst = time.Time{}
+		*null = true
+		return nil
+	case time.Time:
+		*dst = v
+	case []byte:
+		return ScanTime(string(v), dst, null)
+	case string:
+		t, err := time.Parse(time.RFC3339Nano, v)
+		if err != nil {
+			return fmt.Errorf("ql: cannot scan %q into time.Time: %v", v, err)
+		}
+		*dst = t
+	default:
+		return fmt.Errorf("ql: cannot scan %T into time.Time", src)
+	}
+	*null = false
+	return nil
+}
+
+// BindBool returns the argument to pass for an optional boolean, v is either a
+// bool, a *bool or a sql.NullBool. A nil pointer and an invalid sql.NullBool
+// are bound as NULL.
+func BindBool(v interface{}) (interface{}, error) {
+	switch b := v.(type) {
+	case bool:
+		return b, nil
+	case *bool:
+		if b == nil {
+			return nil, nil
+		}
+		return *b, nil
+	case sql.NullBool:
+		if !b.Valid {
+			return nil, nil
+		}
+		return b.Bool, nil
+	default:
+		return nil, fmt.Errorf("ql: cannot bind %T as bool", v)
+	}
+}
+
+// ScanBool stores src, a value read from a bool column, in dst which is either
+// a **bool or a *sql.NullBool. NULL is stored as a nil pointer or an invalid
+// sql.NullBool.
+func ScanBool(src interface{}, dst interface{}) error {
+	var n sql.NullBool
+	if err := n.Scan(src); err != nil {
+		return fmt.Errorf("ql: cannot scan %T into bool: %v", src, err)
+	}
+	switch d := dst.(type) {
+	case **bool:
+		*d = nil
+		if n.Valid {
+			b := n.Bool
+			*d = &b
+		}
+	case *sql.NullBool:
+		*d = n
+	default:
+		return fmt.Errorf("ql: cannot scan bool into %T", dst)
+	}
+	return nil
+}
+
+// BindNull returns the argument to pass for v, a value of the generic
+// sql.Null[T] type or a pointer to one. An invalid value is bound as NULL and
+// a valid one as its V field, converted to a type the driver accepts since
+// database/sql does not do it for the value returned by sql.Null[T]. The big
+// number types are passed as text, see CoerceValue.
+//
+// Reading the column back needs no help, *sql.Null[T] is a sql.Scanner.
+func BindNull(v interface{}) (interface{}, error) {
+	rv := reflect.ValueOf(v)
+	if rv.Kind() == reflect.Ptr {
+		if rv.IsNil() {
+			return nil, nil
+		}
+		rv = rv.Elem()
+	}
+	if !rv.IsValid() || !isNullType(rv.Type()) || rv.Kind() != reflect.Struct {
+		return nil, fmt.Errorf("ql: cannot bind %T as sql.Null", v)
+	}
+	valid, value := rv.FieldByName("Valid"), rv.FieldByName("V")
+	if !valid.IsValid() || !value.IsValid() {
+		return nil, fmt.Errorf("ql: cannot bind %T as sql.Null", v)
+	}
+	if !valid.Bool() {
+		return nil, nil
+	}
+	return driver.DefaultParameterConverter.ConvertValue(encodeValue(value.Interface()))
+}
+
+// CoerceValue converts v to the value to bind for a column of type
+// targetQLType. The result is one of the types the driver accepts, so it has
+// to be bound with a conversion to the column type, like int8($1):
+//
+//	integers        int64, checked against the range of the column type
+//	floats          float64
+//	bigint, bigrat  their text, a big.Int, big.Rat, Decimal or any number
+//	duration        int64 nanoseconds
+//	time            time.Time
+//	blob            []byte
+//
+// Pointers are dereferenced, nil and a nil pointer are bound as NULL. An error
+// is returned when v can not be represented in the column, like a string for
+// an int column or 300 for an int8 one. The complex types are not supported,
+// the driver has no way of binding them.
+func CoerceValue(v interface{}, targetQLType string) (interface{}, error) {
+	rv := reflect.ValueOf(v)
+	for rv.Kind() == reflect.Ptr {
+		if rv.IsNil() {
+			return nil, nil
+		}
+		rv = rv.Elem()
+	}
+	if !rv.IsValid() {
+		return nil, nil
+	}
+	typ := canonicalType(targetQLType)
+	x := rv.Interface()
+	switch x := x.(type) {
+	case big.Int:
+		return coerceBig(&x, nil, typ)
+	case big.Rat:
+		return coerceBig(nil, &x, typ)
+	case Decimal:
+		return coerceBig(nil, x.Rat(), typ)
+	case time.Duration:
+		if typ == "duration" {
+			return int64(x), nil
+		}
+	}
+	kind := rv.Kind()
+	if n, ok := numericTypes[typ]; ok {
+		switch n.family {
+		case familySigned, familyUnsigned:
+			var i int64
+			switch {
+			case kind >= reflect.Int && kind <= reflect.Int64:
+				i = rv.Int()
+			case kind >= reflect.Uint && kind <= reflect.Uintptr:
+				if rv.Uint() > math.MaxInt64 {
+					return nil, fmt.Errorf("ql: %d overflows the int64 the driver binds", rv.Uint())
+				}
+				i = int64(rv.Uint())
+			default:
+				return nil, fmt.Errorf("ql: cannot coerce %T to %s", v, typ)
+			}
+			if !inRange(i, n.family, n.bits) {
+				return nil, fmt.Errorf("ql: %d overflows %s", i, typ)
+			}
+			return i, nil
+		case familyFloat:
+			switch {
+			case kind >= reflect.Int && kind <= reflect.Int64:
+				return float64(rv.Int()), nil
+			case kind >= reflect.Uint && kind <= reflect.Uintptr:
+				return float64(rv.Uint()), nil
+			case kind == reflect.Float32 || kind == reflect.Float64:
+				return rv.Float(), nil
+			}
+		}
+		return nil, fmt.Errorf("ql: cannot coerce %T to %s", v, typ)
+	}
+	switch typ {
+	case "bool":
+		if kind == reflect.Bool {
+			return rv.Bool(), nil
+		}
+	case "string":
+		if kind == reflect.String {
+			return rv.String(), nil
+		}
+	case "blob":
+		switch {
+		case kind == reflect.String:
+			return []byte(rv.String()), nil
+		case kind == reflect.Slice && rv.Type().Elem().Kind() == reflect.Uint8:
+			return rv.Bytes(), nil
+		}
+	case "time":
+		if rv.Type().ConvertibleTo(timeType) && kind == reflect.Struct {
+			return rv.Convert(timeType).Interface(), nil
+		}
+	case "duration":
+		if kind >= reflect.Int && kind <= reflect.Int64 {
+			return rv.Int(), nil
+		}
+	case "bigint", "bigrat":
+		switch {
+		case kind >= reflect.Int && kind <= reflect.Int64:
+			return coerceBig(big.NewInt(rv.Int()), nil, typ)
+		case kind >= reflect.Uint && kind <= reflect.Uintptr:
+			return coerceBig(new(big.Int).SetUint64(rv.Uint()), nil, typ)
+		case kind == reflect.Float32 || kind == reflect.Float64:
+			r, ok := new(big.Rat).SetString(strconv.FormatFloat(rv.Float(), 'g', -1, 64))
+			if !ok {
+				return nil, fmt.Errorf("ql: cannot coerce %v to %s", rv.Float(), typ)
+			}
+			return coerceBig(nil, r, typ)
+		}
+	}
+	return nil, fmt.Errorf("ql: cannot coerce %T to %s", v, typ)
+}
+
+// coerceBig returns the text of i or r for a bigint or bigrat column. A
+// rational is only accepted for a bigint column when it is an integer.
+func coerceBig(i *big.Int, r *big.Rat, typ string) (interface{}, error) {
+	switch typ {
+	case "bigint":
+		if i == nil && r.IsInt() {
+			i = r.Num()
+		}
+		if i != nil {
+			return i.String(), nil
+		}
+		return nil, fmt.Errorf("ql: %s is not an integer", r.RatString())
+	case "bigrat":
+		if r == nil {
+			r = new(big.Rat).SetInt(i)
+		}
+		return r.String(), nil
+	}
+	if i != nil {
+		return nil, fmt.Errorf("ql: cannot coerce big.Int to %s", typ)
+	}
+	return nil, fmt.Errorf("ql: cannot coerce big.Rat to %s", typ)
+}
+
+// inRange returns true if i fits in an integer of the given family and size.
+func inRange(i int64, family typeFamily, bits int) bool {
+	switch family {
+	case familySigned:
+		return bits == 64 || i >= -1<<(bits-1) && i < 1<<(bits-1)
+	case familyUnsigned:
+		return i >= 0 && (bits == 64 || i < 1<<bits)
+	}
+	return false
+}
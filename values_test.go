@@ -0,0 +1,504 @@
+package ql
+
+import (
+	"bytes"
+	"database/sql"
+	"encoding/base64"
+	"fmt"
+	"math"
+	"math/big"
+	"reflect"
+	"strings"
+	"testing"
+	"time"
+)
+
+type Attachment struct {
+	Data []byte
+}
+
+func TestQL_SetBlobAsBase64(t *testing.T) {
+	data := []byte{0, 1, 2, 'q', 'l', 255}
+	for _, v := range []struct {
+		base64 bool
+		typ    string
+	}{
+		{false, "blob"},
+		{true, "string"},
+	} {
+		q := openMemory(t)
+		q.SetBlobAsBase64(v.base64)
+		s, err := q.CreateTableSQL("attachments", modelFields(t, &Attachment{}))
+		if err != nil {
+			t.Fatal(err)
+		}
+		execTx(t, q.db, s)
+		cols, err := columns(q.db, "attachments")
+		if err != nil {
+			t.Fatal(err)
+		}
+		if len(cols) != 1 || cols[0].Type != v.typ {
+			t.Errorf("expected a %s column got %v", v.typ, cols)
+		}
+		arg, err := q.BindBlob(data)
+		if err != nil {
+			t.Fatal(err)
+		}
+		execTx(t, q.db, "INSERT INTO attachments VALUES ($1)", arg)
+		var src interface{}
+		err = q.db.QueryRow("SELECT data FROM attachments").Scan(&src)
+		if err != nil {
+			t.Fatal(err)
+		}
+		var o []byte
+		if err = q.ScanBlob(src, &o); err != nil {
+			t.Fatal(err)
+		}
+		if !bytes.Equal(o, data) {
+			t.Errorf("expected %v got %v", data, o)
+		}
+		execTx(t, q.db, "DROP TABLE attachments")
+	}
+}
+
+func TestQL_SetBlobAsBase64Rows(t *testing.T) {
+	data := []byte{0, 1, 2, 'q', 'l', 255}
+	q := openMemory(t)
+	q.SetBlobAsBase64(true)
+	execTx(t, q.db, "CREATE TABLE attachments (name string, data blob)")
+	_, err := q.ImportRows("attachments", []map[string]interface{}{
+		{"name": "a", "data": data},
+		{"name": "b", "data": []byte(nil)},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	var stored []byte
+	err = q.db.QueryRow(`SELECT data FROM attachments WHERE name == "a"`).Scan(&stored)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if e := base64.StdEncoding.EncodeToString(data); string(stored) != e {
+		t.Errorf("expected %s got %s", e, stored)
+	}
+
+	rows, err := q.ExportRows("attachments")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(rows) != 2 {
+		t.Fatalf("expected 2 rows got %d", len(rows))
+	}
+	if v, ok := rows[0]["data"].([]byte); !ok || !bytes.Equal(v, data) {
+		t.Errorf("expected %v got %#v", data, rows[0]["data"])
+	}
+	if v := rows[1]["data"]; v != nil {
+		t.Errorf("expected nil got %#v", v)
+	}
+
+	var b bytes.Buffer
+	if err = q.ExportCSV("attachments", &b); err != nil {
+		t.Fatal(err)
+	}
+	execTx(t, q.db, "DELETE FROM attachments")
+	if _, err = q.ImportCSV("attachments", &b); err != nil {
+		t.Fatal(err)
+	}
+	rows, err = q.ExportRows("attachments")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if v, ok := rows[0]["data"].([]byte); !ok || !bytes.Equal(v, data) {
+		t.Errorf("expected %v after the CSV round trip got %#v", data, rows[0]["data"])
+	}
+}
+
+type caseTransformer struct{}
+
+func (caseTransformer) OnStore(v interface{}) (interface{}, error) {
+	s, ok := v.(string)
+	if !ok {
+		return nil, fmt.Errorf("expected string got %T", v)
+	}
+	return strings.ToUpper(s), nil
+}
+
+func (caseTransformer) OnLoad(v interface{}) (interface{}, error) {
+	switch s := v.(type) {
+	case string:
+		return strings.ToLower(s), nil
+	case []byte:
+		return strings.ToLower(string(s)), nil
+	}
+	return nil, fmt.Errorf("expected string got %T", v)
+}
+
+func TestQL_RegisterTransformer(t *testing.T) {
+	q := openMemory(t)
+	execTx(t, q.db, "CREATE TABLE users (name string, city string)")
+	q.RegisterTransformer("users", "name", caseTransformer{})
+
+	name, err := q.BindValue("users", "name", "gernest")
+	if err != nil {
+		t.Fatal(err)
+	}
+	city, err := q.BindValue("users", "city", "Mwanza")
+	if err != nil {
+		t.Fatal(err)
+	}
+	execTx(t, q.db, "INSERT INTO users VALUES ($1, $2)", name, city)
+
+	var storedName, storedCity string
+	err = q.db.QueryRow("SELECT name, city FROM users").Scan(&storedName, &storedCity)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if storedName != "GERNEST" {
+		t.Errorf("expected GERNEST got %s", storedName)
+	}
+	if storedCity != "Mwanza" {
+		t.Errorf("expected Mwanza got %s", storedCity)
+	}
+	v, err := q.ScanValue("users", "name", storedName)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if v != "gernest" {
+		t.Errorf("expected gernest got %v", v)
+	}
+	if _, err = q.BindValue("users", "name", 1); err == nil {
+		t.Error("expected an error")
+	}
+
+	q.RegisterTransformer("users", "name", nil)
+	v, err = q.ScanValue("users", "name", storedName)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if v != "GERNEST" {
+		t.Errorf("expected GERNEST got %v", v)
+	}
+}
+
+func TestQL_RegisterTransformerRows(t *testing.T) {
+	q := openMemory(t)
+	execTx(t, q.db, "CREATE TABLE users (name string, city string)")
+	q.RegisterTransformer("users", "name", caseTransformer{})
+
+	_, err := q.ImportRows("users", []map[string]interface{}{
+		{"name": "gernest", "city": "Mwanza"},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	err = q.BatchInsert(q.db, "users", []string{"name", "city"}, [][]interface{}{{"ql", "Arusha"}})
+	if err != nil {
+		t.Fatal(err)
+	}
+	rows, err := q.db.Query("SELECT name FROM users ORDER BY id()")
+	if err != nil {
+		t.Fatal(err)
+	}
+	var stored []string
+	for rows.Next() {
+		var name string
+		if err = rows.Scan(&name); err != nil {
+			t.Fatal(err)
+		}
+		stored = append(stored, name)
+	}
+	_ = rows.Close()
+	if e := []string{"GERNEST", "QL"}; !reflect.DeepEqual(stored, e) {
+		t.Errorf("expected %v got %v", e, stored)
+	}
+
+	execTx(t, q.db, `UPDATE users name = "MIXED" WHERE city == "Arusha"`)
+	exported, err := q.ExportRows("users")
+	if err != nil {
+		t.Fatal(err)
+	}
+	var names, cities []interface{}
+	for _, row := range exported {
+		names = append(names, row["name"])
+		cities = append(cities, row["city"])
+	}
+	if e := []interface{}{"gernest", "mixed"}; !reflect.DeepEqual(names, e) {
+		t.Errorf("expected %v got %v", e, names)
+	}
+	if e := []interface{}{"Mwanza", "Arusha"}; !reflect.DeepEqual(cities, e) {
+		t.Errorf("expected %v got %v", e, cities)
+	}
+
+	if _, err = q.ImportRows("users", []map[string]interface{}{{"name": 1}}); err == nil {
+		t.Error("expected the error of the transformer")
+	}
+}
+
+func TestScanTime(t *testing.T) {
+	q := openMemory(t)
+	now := time.Date(2017, 3, 1, 10, 30, 0, 0, time.UTC)
+	execTx(t, q.db, migration)
+	execTx(t, q.db, "INSERT INTO Orders VALUES (1, $1), (2, NULL)", now)
+
+	sample := []struct {
+		customer int64
+		value    time.Time
+		null     bool
+	}{
+		{1, now, false},
+		{2, time.Time{}, true},
+	}
+	for _, v := range sample {
+		var src interface{}
+		err := q.db.QueryRow("SELECT Date FROM Orders WHERE CustomerID == $1", v.customer).Scan(&src)
+		if err != nil {
+			t.Fatal(err)
+		}
+		var o time.Time
+		null := !v.null
+		if err = ScanTime(src, &o, &null); err != nil {
+			t.Fatal(err)
+		}
+		if null != v.null {
+			t.Errorf("expected null to be %v", v.null)
+		}
+		if !o.Equal(v.value) {
+			t.Errorf("expected %v got %v", v.value, o)
+		}
+	}
+
+	var o time.Time
+	var null bool
+	if err := ScanTime(int64(1), &o, &null); err == nil {
+		t.Error("expected an error")
+	}
+}
+
+func TestQL_BindFloat(t *testing.T) {
+	q := Memory()
+	for _, v := range []float64{math.NaN(), math.Inf(1), math.Inf(-1)} {
+		if _, err := q.BindFloat(v); err == nil {
+			t.Errorf("expected %v to be rejected", v)
+		}
+	}
+	o, err := q.BindFloat(1.5)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if o != 1.5 {
+		t.Errorf("expected 1.5 got %v", o)
+	}
+
+	q.SetNonFiniteFloatAsNull(true)
+	for _, v := range []float64{math.NaN(), math.Inf(1), math.Inf(-1)} {
+		o, err = q.BindFloat(v)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if o != nil {
+			t.Errorf("expected nil got %v", o)
+		}
+	}
+}
+
+type Flags struct {
+	ID       int64
+	Archived *bool
+	Verified sql.NullBool
+}
+
+func TestBindBool(t *testing.T) {
+	q := openMemory(t)
+	for _, f := range modelFields(t, &Flags{}) {
+		if f.Name == "ID" {
+			continue
+		}
+		s, err := q.DataTypeOf(f)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if s != "bool" {
+			t.Errorf("%s: expected bool got %s", f.Name, s)
+		}
+	}
+
+	execTx(t, q.db, "CREATE TABLE flags (archived bool, verified bool)")
+	yes := true
+	for _, v := range []struct {
+		archived *bool
+		verified sql.NullBool
+	}{
+		{&yes, sql.NullBool{Bool: false, Valid: true}},
+		{nil, sql.NullBool{}},
+	} {
+		a, err := BindBool(v.archived)
+		if err != nil {
+			t.Fatal(err)
+		}
+		b, err := BindBool(v.verified)
+		if err != nil {
+			t.Fatal(err)
+		}
+		execTx(t, q.db, "INSERT INTO flags VALUES ($1, $2)", a, b)
+	}
+	rows, err := q.db.Query("SELECT archived, verified FROM flags ORDER BY id()")
+	if err != nil {
+		t.Fatal(err)
+	}
+	var archived []*bool
+	var verified []sql.NullBool
+	for rows.Next() {
+		var a, b interface{}
+		if err = rows.Scan(&a, &b); err != nil {
+			t.Fatal(err)
+		}
+		var ap *bool
+		var bn sql.NullBool
+		if err = ScanBool(a, &ap); err != nil {
+			t.Fatal(err)
+		}
+		if err = ScanBool(b, &bn); err != nil {
+			t.Fatal(err)
+		}
+		archived = append(archived, ap)
+		verified = append(verified, bn)
+	}
+	_ = rows.Close()
+	if len(archived) != 2 {
+		t.Fatalf("expected 2 records got %d", len(archived))
+	}
+	if archived[0] == nil || !*archived[0] || archived[1] != nil {
+		t.Errorf("expected [true nil] got %v", archived)
+	}
+	if verified[0] != (sql.NullBool{Valid: true}) || verified[1].Valid {
+		t.Errorf("expected [false NULL] got %v", verified)
+	}
+	if _, err = BindBool("yes"); err == nil {
+		t.Error("expected an error")
+	}
+}
+
+func TestQL_MaxBlobSize(t *testing.T) {
+	q := Memory()
+	if q.MaxBlobSize() <= 0 {
+		t.Errorf("expected a positive limit got %d", q.MaxBlobSize())
+	}
+	big := make([]byte, 1025)
+	if _, err := q.BindBlob(big); err != nil {
+		t.Errorf("expected no enforcement by default got %v", err)
+	}
+	q.SetMaxBlobSize(1024)
+	if q.MaxBlobSize() != 1024 {
+		t.Errorf("expected 1024 got %d", q.MaxBlobSize())
+	}
+	q.SetEnforceBlobSize(true)
+	if _, err := q.BindBlob(big); err == nil {
+		t.Error("expected an error for an oversize blob")
+	}
+	if _, err := q.BindBlob(big[:1024]); err != nil {
+		t.Error(err)
+	}
+	q.SetMaxBlobSize(0)
+	if q.MaxBlobSize() != DefaultMaxBlobSize {
+		t.Errorf("expected %d got %d", DefaultMaxBlobSize, q.MaxBlobSize())
+	}
+}
+
+func TestQL_SetEnforceBlobSizeRows(t *testing.T) {
+	q := openMemory(t)
+	execTx(t, q.db, "CREATE TABLE attachments (data blob)")
+	q.SetMaxBlobSize(1024)
+	q.SetEnforceBlobSize(true)
+	_, err := q.ImportRows("attachments", []map[string]interface{}{
+		{"data": make([]byte, 1024)},
+		{"data": make([]byte, 1025)},
+	})
+	if err == nil {
+		t.Error("expected an error for an oversize blob")
+	}
+	err = q.BatchInsert(q.db, "attachments", []string{"data"}, [][]interface{}{{make([]byte, 1025)}})
+	if err == nil {
+		t.Error("expected an error for an oversize blob")
+	}
+	var n int
+	if err = q.db.QueryRow("SELECT count() FROM attachments").Scan(&n); err != nil {
+		t.Fatal(err)
+	}
+	if n != 0 {
+		t.Errorf("expected no records got %d", n)
+	}
+	if _, err = q.ImportRows("attachments", []map[string]interface{}{{"data": make([]byte, 1024)}}); err != nil {
+		t.Error(err)
+	}
+}
+
+func TestCoerceValue(t *testing.T) {
+	now := time.Now()
+	n := uint64(7)
+	sample := []struct {
+		v   interface{}
+		typ string
+		exp interface{}
+	}{
+		{int8(5), "int64", int64(5)},
+		{uint64(200), "uint8", int64(200)},
+		{&n, "int", int64(7)},
+		{3, "float32", float64(3)},
+		{now, "time", now},
+		{time.Second, "duration", int64(time.Second)},
+		{*big.NewInt(42), "bigint", "42"},
+		{big.NewRat(1, 3), "bigrat", "1/3"},
+		{Decimal{Units: 250, Scale: 2}, "bigrat", "5/2"},
+		{0.5, "bigrat", "1/2"},
+		{"abc", "blob", []byte("abc")},
+		{"abc", "string", "abc"},
+		{nil, "string", nil},
+		{(*int)(nil), "int64", nil},
+	}
+	for _, v := range sample {
+		got, err := CoerceValue(v.v, v.typ)
+		if err != nil {
+			t.Errorf("%v to %s: %v", v.v, v.typ, err)
+			continue
+		}
+		if !reflect.DeepEqual(got, v.exp) {
+			t.Errorf("%v to %s: expected %#v got %#v", v.v, v.typ, v.exp, got)
+		}
+	}
+	for _, v := range []struct {
+		v   interface{}
+		typ string
+	}{
+		{"42", "int"},
+		{300, "int8"},
+		{-1, "uint32"},
+		{uint64(math.MaxUint64), "uint64"},
+		{1.5, "int64"},
+		{Decimal{Units: 250, Scale: 2}, "bigint"},
+		{true, "string"},
+		{1 + 2i, "complex128"},
+	} {
+		if _, err := CoerceValue(v.v, v.typ); err == nil {
+			t.Errorf("%v to %s: expected an error", v.v, v.typ)
+		}
+	}
+
+	q := openMemory(t)
+	execTx(t, q.db, "CREATE TABLE Amounts (Small int8, Big bigint)")
+	small, err := CoerceValue(uint16(12), "int8")
+	if err != nil {
+		t.Fatal(err)
+	}
+	large, err := CoerceValue(uint64(math.MaxInt64), "bigint")
+	if err != nil {
+		t.Fatal(err)
+	}
+	execTx(t, q.db, "INSERT INTO Amounts VALUES (int8($1), bigint($2))", small, large)
+	s, err := q.ScalarString("SELECT string(Big) FROM Amounts WHERE Small == 12")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if s != "9223372036854775807" {
+		t.Errorf("expected 9223372036854775807 got %s", s)
+	}
+}